@@ -0,0 +1,210 @@
+package cache
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/cortexproject/cortex/pkg/util"
+)
+
+// Cache is a generic key-value cache, implemented by each of the backends in
+// this package (memcached, Redis, in-memory) and composed by TieredCache.
+type Cache interface {
+	// Store writes keys[i] = bufs[i] for every i. Implementations should
+	// make a best effort and not fail the caller just because a cache
+	// write was dropped.
+	Store(ctx context.Context, keys []string, bufs [][]byte) error
+
+	// Fetch looks up keys and returns the values found, keyed by the
+	// original key, plus the subset of keys that were not found.
+	Fetch(ctx context.Context, keys []string) (found map[string][]byte, missing []string)
+
+	Stop()
+}
+
+// Config configures which Cache backend to use.
+type Config struct {
+	Backend string `yaml:"backend"`
+	// TieredL2Backend selects which backend a "tiered" cache uses as its L2,
+	// behind the in-memory L1. Supported values: memcached, redis.
+	TieredL2Backend string `yaml:"tiered_l2_backend"`
+
+	Memcached   MemcachedClientConfig `yaml:"memcached"`
+	Redis       RedisConfig           `yaml:"redis"`
+	InMemory    InMemoryCacheConfig   `yaml:"inmemory"`
+	Compression CompressionConfig     `yaml:"compression"`
+}
+
+// RegisterFlagsWithPrefix adds the flags required to config this to the given FlagSet.
+func (cfg *Config) RegisterFlagsWithPrefix(prefix, description string, f *flag.FlagSet) {
+	f.StringVar(&cfg.Backend, prefix+"cache.backend", "memcached", description+"Cache backend to use: memcached, redis, inmemory or tiered (inmemory in front of memcached/redis).")
+	f.StringVar(&cfg.TieredL2Backend, prefix+"cache.tiered-l2-backend", "memcached", description+"Backend the tiered cache uses as its L2, behind the in-memory L1: memcached or redis.")
+	cfg.Memcached.RegisterFlagsWithPrefix(prefix, description, f)
+	cfg.Redis.RegisterFlagsWithPrefix(prefix, description, f)
+	cfg.InMemory.RegisterFlagsWithPrefix(prefix, description, f)
+	cfg.Compression.RegisterFlagsWithPrefix(prefix, description, f)
+}
+
+// New creates a Cache from cfg, wrapping it with per-backend metrics and, if
+// configured, transparent compression. name is used to label those metrics,
+// the same way it already labels the memcached client's metrics.
+func New(cfg Config, name string) (Cache, error) {
+	c, err := newBackend(cfg, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !cfg.Compression.Enabled {
+		return c, nil
+	}
+	return NewCompressingCache(cfg.Compression, cfg.Backend, c)
+}
+
+func newBackend(cfg Config, name string) (Cache, error) {
+	switch cfg.Backend {
+	case "memcached":
+		return instrument(name, "memcached", NewMemcachedClient(cfg.Memcached, name)), nil
+	case "redis":
+		return newInstrumentedRedis(cfg.Redis, name)
+	case "inmemory":
+		return instrument(name, "inmemory", NewInMemoryCache(cfg.InMemory)), nil
+	case "tiered":
+		l2cfg := cfg
+		l2cfg.Backend = cfg.TieredL2Backend
+		l2, err := newBackend(l2cfg, name)
+		if err != nil {
+			return nil, err
+		}
+		l1 := instrument(name, "inmemory", NewInMemoryCache(cfg.InMemory))
+		return NewTieredCache(l1, l2), nil
+	default:
+		return nil, fmt.Errorf("unrecognized cache backend: %s", cfg.Backend)
+	}
+}
+
+func newInstrumentedRedis(cfg RedisConfig, name string) (Cache, error) {
+	c, err := NewRedisCache(cfg, name)
+	if err != nil {
+		return nil, err
+	}
+	return instrument(name, "redis", c), nil
+}
+
+// TieredCache queries a fast, small L1 cache before falling back to a
+// larger, slower L2 (typically Redis or memcached), and backfills L1 on
+// every L2 hit so repeat lookups stay local.
+type TieredCache struct {
+	l1, l2 Cache
+}
+
+// NewTieredCache returns a Cache that checks l1 before l2.
+func NewTieredCache(l1, l2 Cache) *TieredCache {
+	return &TieredCache{l1: l1, l2: l2}
+}
+
+// Store writes through to both layers. An L1 failure (e.g. a value too
+// large for freecache's segment allocation) is logged and doesn't stop the
+// write from reaching L2, since L1 is only a faster path to data that's
+// meant to live durably in L2.
+func (t *TieredCache) Store(ctx context.Context, keys []string, bufs [][]byte) error {
+	if err := t.l1.Store(ctx, keys, bufs); err != nil {
+		level.Warn(util.Logger).Log("msg", "error storing to L1 cache", "err", err)
+	}
+	return t.l2.Store(ctx, keys, bufs)
+}
+
+// Fetch checks l1 first, then l2 for whatever l1 missed, backfilling l1 with
+// what l2 found.
+func (t *TieredCache) Fetch(ctx context.Context, keys []string) (map[string][]byte, []string) {
+	found, missing := t.l1.Fetch(ctx, keys)
+	if len(missing) == 0 {
+		return found, missing
+	}
+
+	l2Found, l2Missing := t.l2.Fetch(ctx, missing)
+	if len(l2Found) > 0 {
+		backfillKeys := make([]string, 0, len(l2Found))
+		backfillBufs := make([][]byte, 0, len(l2Found))
+		for k, v := range l2Found {
+			found[k] = v
+			backfillKeys = append(backfillKeys, k)
+			backfillBufs = append(backfillBufs, v)
+		}
+		// Backfilling L1 is an optimisation; a failure here shouldn't fail
+		// the read that already succeeded against L2.
+		_ = t.l1.Store(ctx, backfillKeys, backfillBufs)
+	}
+
+	return found, l2Missing
+}
+
+// Stop stops both layers.
+func (t *TieredCache) Stop() {
+	t.l1.Stop()
+	t.l2.Stop()
+}
+
+// instrumentedCache wraps a Cache with per-layer hit/miss/latency metrics
+// labelled by backend, so operators can see how each tier of a tiered cache
+// is performing.
+type instrumentedCache struct {
+	Cache
+
+	storeDuration prometheus.Observer
+	fetchDuration prometheus.Observer
+	hits          prometheus.Counter
+	misses        prometheus.Counter
+}
+
+var (
+	cacheRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "cortex",
+		Name:      "cache_request_duration_seconds",
+		Help:      "Total time spent in seconds doing cache requests, by backend and operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"name", "backend", "operation"})
+
+	cacheHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cortex",
+		Name:      "cache_hits_total",
+		Help:      "Total count of keys found in cache, by backend.",
+	}, []string{"name", "backend"})
+
+	cacheMisses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cortex",
+		Name:      "cache_misses_total",
+		Help:      "Total count of keys not found in cache, by backend.",
+	}, []string{"name", "backend"})
+)
+
+func instrument(name, backend string, c Cache) Cache {
+	return &instrumentedCache{
+		Cache:         c,
+		storeDuration: cacheRequestDuration.WithLabelValues(name, backend, "store"),
+		fetchDuration: cacheRequestDuration.WithLabelValues(name, backend, "fetch"),
+		hits:          cacheHits.WithLabelValues(name, backend),
+		misses:        cacheMisses.WithLabelValues(name, backend),
+	}
+}
+
+func (i *instrumentedCache) Store(ctx context.Context, keys []string, bufs [][]byte) error {
+	start := time.Now()
+	err := i.Cache.Store(ctx, keys, bufs)
+	i.storeDuration.Observe(time.Since(start).Seconds())
+	return err
+}
+
+func (i *instrumentedCache) Fetch(ctx context.Context, keys []string) (map[string][]byte, []string) {
+	start := time.Now()
+	found, missing := i.Cache.Fetch(ctx, keys)
+	i.fetchDuration.Observe(time.Since(start).Seconds())
+	i.hits.Add(float64(len(found)))
+	i.misses.Add(float64(len(missing)))
+	return found, missing
+}
@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// fakeCache is a minimal in-memory Cache used to exercise TieredCache and
+// New without depending on a real memcached/redis/freecache backend.
+type fakeCache struct {
+	mu         sync.Mutex
+	values     map[string][]byte
+	storeErr   error
+	storeCalls int
+}
+
+func newFakeCache() *fakeCache {
+	return &fakeCache{values: map[string][]byte{}}
+}
+
+func (f *fakeCache) Store(_ context.Context, keys []string, bufs [][]byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.storeCalls++
+	if f.storeErr != nil {
+		return f.storeErr
+	}
+	for i, k := range keys {
+		f.values[k] = bufs[i]
+	}
+	return nil
+}
+
+func (f *fakeCache) Fetch(_ context.Context, keys []string) (map[string][]byte, []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	found := make(map[string][]byte, len(keys))
+	var missing []string
+	for _, k := range keys {
+		if v, ok := f.values[k]; ok {
+			found[k] = v
+			continue
+		}
+		missing = append(missing, k)
+	}
+	return found, missing
+}
+
+func (f *fakeCache) Stop() {}
+
+func TestTieredCache_Store_WritesBothLayers(t *testing.T) {
+	l1, l2 := newFakeCache(), newFakeCache()
+	tiered := NewTieredCache(l1, l2)
+
+	err := tiered.Store(context.Background(), []string{"a", "b"}, [][]byte{[]byte("1"), []byte("2")})
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+
+	if string(l1.values["a"]) != "1" || string(l1.values["b"]) != "2" {
+		t.Fatalf("expected l1 to have both keys, got %v", l1.values)
+	}
+	if string(l2.values["a"]) != "1" || string(l2.values["b"]) != "2" {
+		t.Fatalf("expected l2 to have both keys, got %v", l2.values)
+	}
+}
+
+func TestTieredCache_Fetch_FallsBackToL2AndBackfillsL1(t *testing.T) {
+	l1, l2 := newFakeCache(), newFakeCache()
+	l2.values["a"] = []byte("1")
+	tiered := NewTieredCache(l1, l2)
+
+	found, missing := tiered.Fetch(context.Background(), []string{"a"})
+	if len(missing) != 0 {
+		t.Fatalf("expected no misses, got %v", missing)
+	}
+	if string(found["a"]) != "1" {
+		t.Fatalf("expected a=1, got %v", found)
+	}
+	if string(l1.values["a"]) != "1" {
+		t.Fatalf("expected l2 hit to backfill l1, l1 has %v", l1.values)
+	}
+}
+
+func TestTieredCache_Fetch_PartialBatch(t *testing.T) {
+	l1, l2 := newFakeCache(), newFakeCache()
+	l1.values["a"] = []byte("1")  // hits in l1
+	l2.values["b"] = []byte("2")  // only in l2
+	// "c" is missing from both.
+	tiered := NewTieredCache(l1, l2)
+
+	found, missing := tiered.Fetch(context.Background(), []string{"a", "b", "c"})
+	if len(found) != 2 || string(found["a"]) != "1" || string(found["b"]) != "2" {
+		t.Fatalf("unexpected found: %v", found)
+	}
+	if len(missing) != 1 || missing[0] != "c" {
+		t.Fatalf("expected only c missing, got %v", missing)
+	}
+}
+
+func TestTieredCache_Store_AttemptsL2EvenIfL1Fails(t *testing.T) {
+	l1, l2 := newFakeCache(), newFakeCache()
+	l1.storeErr = errors.New("l1 boom")
+	tiered := NewTieredCache(l1, l2)
+
+	if err := tiered.Store(context.Background(), []string{"a"}, [][]byte{[]byte("1")}); err != nil {
+		t.Fatalf("expected Store to succeed via l2 despite l1 failing, got %v", err)
+	}
+	if string(l2.values["a"]) != "1" {
+		t.Fatalf("expected l2 to still receive the write, got %v", l2.values)
+	}
+}
+
+func TestNew_UnrecognizedBackend(t *testing.T) {
+	cfg := Config{Backend: "not-a-real-backend"}
+	if _, err := New(cfg, "test"); err == nil {
+		t.Fatal("expected an error for an unrecognized backend")
+	}
+}
+
+func TestNew_Tiered(t *testing.T) {
+	cfg := Config{
+		Backend:         "tiered",
+		TieredL2Backend: "inmemory",
+		InMemory:        InMemoryCacheConfig{MaxSizeBytes: 1024 * 1024},
+	}
+
+	c, err := New(cfg, "test")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Stop()
+
+	if _, ok := c.(*TieredCache); !ok {
+		t.Fatalf("expected a *TieredCache, got %T", c)
+	}
+}
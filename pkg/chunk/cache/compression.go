@@ -0,0 +1,267 @@
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/go-kit/kit/log/level"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/cortexproject/cortex/pkg/util"
+)
+
+// compressionMagic marks a value as having gone through codecCache, whether
+// or not it ended up compressed (values below MinSizeBytes still get a
+// codecNone header). A value whose first byte is not compressionMagic (such
+// as one written before this header existed) is returned as stored, except
+// that with checksums enabled its last 8 bytes are still read as a checksum,
+// so such a value normally reads as a miss.
+const compressionMagic byte = 0xc5
+
+type valueCodec byte
+
+const (
+	codecNone valueCodec = iota
+	codecSnappy
+	codecZstd
+)
+
+func (c valueCodec) String() string {
+	switch c {
+	case codecSnappy:
+		return "snappy"
+	case codecZstd:
+		return "zstd"
+	default:
+		return "none"
+	}
+}
+
+// CompressionConfig configures the transparent compression and integrity
+// checking applied on top of a Cache.
+type CompressionConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	Codec        string `yaml:"codec"`
+	MinSizeBytes int    `yaml:"min_size_bytes"`
+	Checksum     bool   `yaml:"checksum"`
+}
+
+// RegisterFlagsWithPrefix adds the flags required to config this to the given FlagSet.
+func (cfg *CompressionConfig) RegisterFlagsWithPrefix(prefix, description string, f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, prefix+"cache.compression.enabled", false, description+"Compress values above -"+prefix+"cache.compression.min-size before storing them in the cache.")
+	f.StringVar(&cfg.Codec, prefix+"cache.compression.codec", "snappy", description+"Codec used to compress cache values: snappy or zstd.")
+	f.IntVar(&cfg.MinSizeBytes, prefix+"cache.compression.min-size", 1024, description+"Minimum value size, in bytes, before it gets compressed.")
+	f.BoolVar(&cfg.Checksum, prefix+"cache.compression.checksum", false, description+"Append an xxhash64 checksum to cached values, and treat a mismatch on read as a cache miss.")
+}
+
+var (
+	compressionRatio = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "cortex",
+		Name:      "cache_compression_ratio",
+		Help:      "Ratio of compressed to uncompressed size for cache values that were compressed.",
+		Buckets:   []float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9, 1},
+	}, []string{"backend"})
+
+	compressionCPUSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "cortex",
+		Name:      "cache_compression_cpu_seconds",
+		Help:      "Time spent compressing and decompressing cache values.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"backend", "operation"})
+
+	corruptItems = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cortex",
+		Name:      "cache_corrupt_items_total",
+		Help:      "Total number of cache values that failed their integrity check on read and were treated as a miss.",
+	}, []string{"backend"})
+)
+
+// codecCache wraps a Cache, transparently compressing values above
+// MinSizeBytes on Store and decompressing them on Fetch, and optionally
+// verifying an appended checksum.
+type codecCache struct {
+	Cache
+	cfg     CompressionConfig
+	codec   valueCodec
+	backend string
+
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+
+	compressDuration   prometheus.Observer
+	decompressDuration prometheus.Observer
+	ratio              prometheus.Observer
+	corrupt            prometheus.Counter
+}
+
+// NewCompressingCache wraps next with transparent compression and integrity
+// checking, as configured by cfg.
+func NewCompressingCache(cfg CompressionConfig, backend string, next Cache) (Cache, error) {
+	var codec valueCodec
+	switch cfg.Codec {
+	case "snappy":
+		codec = codecSnappy
+	case "zstd":
+		codec = codecZstd
+	default:
+		return nil, fmt.Errorf("unrecognised cache compression codec: %s", cfg.Codec)
+	}
+
+	c := &codecCache{
+		Cache:              next,
+		cfg:                cfg,
+		codec:              codec,
+		backend:            backend,
+		compressDuration:   compressionCPUSeconds.WithLabelValues(backend, "compress"),
+		decompressDuration: compressionCPUSeconds.WithLabelValues(backend, "decompress"),
+		ratio:              compressionRatio.WithLabelValues(backend),
+		corrupt:            corruptItems.WithLabelValues(backend),
+	}
+
+	if codec == codecZstd {
+		enc, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, err
+		}
+		dec, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, err
+		}
+		c.zstdEncoder, c.zstdDecoder = enc, dec
+	}
+
+	return c, nil
+}
+
+// Store implements Cache.
+func (c *codecCache) Store(ctx context.Context, keys []string, bufs [][]byte) error {
+	encoded := make([][]byte, len(bufs))
+	for i, buf := range bufs {
+		encoded[i] = c.encode(buf)
+	}
+	return c.Cache.Store(ctx, keys, encoded)
+}
+
+// Fetch implements Cache.
+func (c *codecCache) Fetch(ctx context.Context, keys []string) (map[string][]byte, []string) {
+	found, missing := c.Cache.Fetch(ctx, keys)
+
+	decoded := make(map[string][]byte, len(found))
+	for key, buf := range found {
+		v, ok := c.decode(buf)
+		if !ok {
+			c.corrupt.Inc()
+			missing = append(missing, key)
+			continue
+		}
+		decoded[key] = v
+	}
+	return decoded, missing
+}
+
+// encode compresses buf if it's large enough to be worth it, and appends a
+// checksum if configured. The header is [magic byte][codec id][uvarint
+// uncompressed length], followed by the (possibly compressed) payload.
+// Values below MinSizeBytes still get a [magic byte][codecNone] header with
+// no length (the payload is already the value verbatim): leaving them
+// unprefixed would make decode misidentify any value whose first raw byte
+// happened to equal compressionMagic as a corrupt compressed entry.
+func (c *codecCache) encode(buf []byte) []byte {
+	if len(buf) < c.cfg.MinSizeBytes {
+		header := []byte{compressionMagic, byte(codecNone)}
+		return c.appendChecksum(buf, append(header, buf...))
+	}
+
+	start := time.Now()
+	var compressed []byte
+	switch c.codec {
+	case codecSnappy:
+		compressed = snappy.Encode(nil, buf)
+	case codecZstd:
+		compressed = c.zstdEncoder.EncodeAll(buf, nil)
+	}
+	c.compressDuration.Observe(time.Since(start).Seconds())
+	c.ratio.Observe(float64(len(compressed)) / float64(len(buf)))
+
+	lenBuf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(lenBuf, uint64(len(buf)))
+
+	header := append([]byte{compressionMagic, byte(c.codec)}, lenBuf[:n]...)
+	return c.appendChecksum(buf, append(header, compressed...))
+}
+
+// appendChecksum appends the checksum of original (the uncompressed value)
+// to encoded, if checksums are enabled.
+func (c *codecCache) appendChecksum(original, encoded []byte) []byte {
+	if !c.cfg.Checksum {
+		return encoded
+	}
+	sum := xxhash.Sum64(original)
+	out := make([]byte, len(encoded)+8)
+	copy(out, encoded)
+	binary.LittleEndian.PutUint64(out[len(encoded):], sum)
+	return out
+}
+
+// decode reverses encode. ok is false if a checksum was present and didn't
+// match, in which case the caller should treat this as a miss.
+func (c *codecCache) decode(buf []byte) (value []byte, ok bool) {
+	payload := buf
+	var wantSum uint64
+	haveChecksum := c.cfg.Checksum && len(buf) >= 8
+	if haveChecksum {
+		payload = buf[:len(buf)-8]
+		wantSum = binary.LittleEndian.Uint64(buf[len(buf)-8:])
+	}
+
+	value, err := c.decompress(payload)
+	if err != nil {
+		level.Warn(util.Logger).Log("msg", "error decompressing cache value", "err", err)
+		return nil, false
+	}
+
+	if haveChecksum && xxhash.Sum64(value) != wantSum {
+		return nil, false
+	}
+	return value, true
+}
+
+func (c *codecCache) decompress(payload []byte) ([]byte, error) {
+	if len(payload) == 0 || payload[0] != compressionMagic {
+		// No header, e.g. a value written before this header existed. A
+		// headerless value whose first byte happens to equal
+		// compressionMagic is not caught here and is decoded as a header.
+		return payload, nil
+	}
+
+	codec := valueCodec(payload[1])
+	if codec == codecNone {
+		// Below MinSizeBytes: header only, no length-prefixed payload.
+		return payload[2:], nil
+	}
+
+	uncompressedLen, n := binary.Uvarint(payload[2:])
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid cache value header")
+	}
+	compressed := payload[2+n:]
+
+	start := time.Now()
+	defer func() { c.decompressDuration.Observe(time.Since(start).Seconds()) }()
+
+	switch codec {
+	case codecSnappy:
+		return snappy.Decode(make([]byte, 0, uncompressedLen), compressed)
+	case codecZstd:
+		return c.zstdDecoder.DecodeAll(compressed, make([]byte, 0, uncompressedLen))
+	default:
+		return nil, fmt.Errorf("unrecognised cache value codec: %d", codec)
+	}
+}
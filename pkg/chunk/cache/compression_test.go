@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func newCodecCache(t *testing.T, codecName string, checksum bool) *codecCache {
+	t.Helper()
+	cfg := CompressionConfig{
+		Enabled:      true,
+		Codec:        codecName,
+		MinSizeBytes: 16,
+		Checksum:     checksum,
+	}
+	c, err := NewCompressingCache(cfg, "test", newFakeCache())
+	if err != nil {
+		t.Fatalf("NewCompressingCache: %v", err)
+	}
+	return c.(*codecCache)
+}
+
+func TestCodecCache_Snappy_RoundTrip(t *testing.T) {
+	c := newCodecCache(t, "snappy", false)
+	value := bytes.Repeat([]byte("x"), 128)
+
+	encoded := c.encode(value)
+	decoded, ok := c.decode(encoded)
+	if !ok {
+		t.Fatal("expected decode to succeed")
+	}
+	if !bytes.Equal(decoded, value) {
+		t.Fatalf("round trip mismatch: got %q, want %q", decoded, value)
+	}
+}
+
+func TestCodecCache_Zstd_RoundTrip(t *testing.T) {
+	c := newCodecCache(t, "zstd", false)
+	value := bytes.Repeat([]byte("y"), 128)
+
+	encoded := c.encode(value)
+	decoded, ok := c.decode(encoded)
+	if !ok {
+		t.Fatal("expected decode to succeed")
+	}
+	if !bytes.Equal(decoded, value) {
+		t.Fatalf("round trip mismatch: got %q, want %q", decoded, value)
+	}
+}
+
+func TestCodecCache_BelowMinSize_RoundTripsUncompressed(t *testing.T) {
+	c := newCodecCache(t, "snappy", false)
+	value := []byte("short")
+
+	encoded := c.encode(value)
+	if encoded[1] != byte(codecNone) {
+		t.Fatalf("expected a codecNone header for a value below MinSizeBytes, got codec id %d", encoded[1])
+	}
+
+	decoded, ok := c.decode(encoded)
+	if !ok {
+		t.Fatal("expected decode to succeed")
+	}
+	if !bytes.Equal(decoded, value) {
+		t.Fatalf("round trip mismatch: got %q, want %q", decoded, value)
+	}
+}
+
+func TestCodecCache_ChecksumMismatch_TreatedAsMiss(t *testing.T) {
+	c := newCodecCache(t, "snappy", true)
+	value := bytes.Repeat([]byte("z"), 128)
+
+	encoded := c.encode(value)
+	// Corrupt a byte in the middle of the payload, leaving the appended
+	// checksum as-is so it no longer matches.
+	encoded[len(encoded)/2] ^= 0xff
+
+	if _, ok := c.decode(encoded); ok {
+		t.Fatal("expected a checksum mismatch to be treated as a miss")
+	}
+}
+
+func TestCodecCache_Checksum_ValidRoundTrip(t *testing.T) {
+	c := newCodecCache(t, "snappy", true)
+	value := bytes.Repeat([]byte("w"), 128)
+
+	encoded := c.encode(value)
+	decoded, ok := c.decode(encoded)
+	if !ok {
+		t.Fatal("expected decode to succeed with a matching checksum")
+	}
+	if !bytes.Equal(decoded, value) {
+		t.Fatalf("round trip mismatch: got %q, want %q", decoded, value)
+	}
+}
+
+func TestCodecCache_Fetch_MarksCorruptValuesAsMissing(t *testing.T) {
+	cfg := CompressionConfig{Enabled: true, Codec: "snappy", MinSizeBytes: 16, Checksum: true}
+	backing := newFakeCache()
+	cache, err := NewCompressingCache(cfg, "test", backing)
+	if err != nil {
+		t.Fatalf("NewCompressingCache: %v", err)
+	}
+
+	if err := cache.Store(context.Background(), []string{"a"}, [][]byte{bytes.Repeat([]byte("v"), 128)}); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	corrupted := backing.values["a"]
+	corrupted[len(corrupted)/2] ^= 0xff
+
+	found, missing := cache.Fetch(context.Background(), []string{"a"})
+	if len(found) != 0 {
+		t.Fatalf("expected no values to be returned for a corrupt entry, got %v", found)
+	}
+	if len(missing) != 1 || missing[0] != "a" {
+		t.Fatalf("expected a to be reported missing, got %v", missing)
+	}
+}
+
+func TestNewCompressingCache_UnrecognisedCodec(t *testing.T) {
+	cfg := CompressionConfig{Enabled: true, Codec: "not-a-real-codec"}
+	_, err := NewCompressingCache(cfg, "test", newFakeCache())
+	if err == nil || !strings.Contains(err.Error(), "unrecognised") {
+		t.Fatalf("expected an 'unrecognised codec' error, got %v", err)
+	}
+}
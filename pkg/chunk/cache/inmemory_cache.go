@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"context"
+	"flag"
+
+	"github.com/coocood/freecache"
+	"github.com/go-kit/kit/log/level"
+
+	"github.com/cortexproject/cortex/pkg/util"
+)
+
+// InMemoryCacheConfig configures an inMemoryCache.
+type InMemoryCacheConfig struct {
+	MaxSizeBytes int `yaml:"max_size_bytes"`
+}
+
+// RegisterFlagsWithPrefix adds the flags required to config this to the given FlagSet.
+func (cfg *InMemoryCacheConfig) RegisterFlagsWithPrefix(prefix, description string, f *flag.FlagSet) {
+	f.IntVar(&cfg.MaxSizeBytes, prefix+"cache.inmemory.max-size-bytes", 100*1024*1024, description+"Maximum size in bytes of the in-memory cache.")
+}
+
+// inMemoryCache is a small, fixed-size, in-process Cache backed by freecache.
+// It's intended for use as an L1 in front of Redis or memcached, not as a
+// cache on its own: it doesn't survive process restarts or coordinate
+// between replicas.
+type inMemoryCache struct {
+	cache *freecache.Cache
+}
+
+// NewInMemoryCache creates a Cache backed by an in-process LRU.
+func NewInMemoryCache(cfg InMemoryCacheConfig) Cache {
+	return &inMemoryCache{
+		cache: freecache.NewCache(cfg.MaxSizeBytes),
+	}
+}
+
+// Store implements Cache. A per-key failure (e.g. a value too large for
+// freecache's segment allocation) is logged and doesn't stop the rest of
+// the batch from being stored, per Cache.Store's best-effort contract.
+func (c *inMemoryCache) Store(_ context.Context, keys []string, bufs [][]byte) error {
+	for i := range keys {
+		// expireSeconds 0 means the entry never expires on its own; it is
+		// still evicted under memory pressure like any other LRU entry.
+		if err := c.cache.Set([]byte(keys[i]), bufs[i], 0); err != nil {
+			level.Warn(util.Logger).Log("msg", "error storing to in-memory cache", "key", keys[i], "err", err)
+		}
+	}
+	return nil
+}
+
+// Fetch implements Cache.
+func (c *inMemoryCache) Fetch(_ context.Context, keys []string) (map[string][]byte, []string) {
+	found := make(map[string][]byte, len(keys))
+	missing := make([]string, 0, len(keys))
+	for _, key := range keys {
+		buf, err := c.cache.Get([]byte(key))
+		if err != nil {
+			missing = append(missing, key)
+			continue
+		}
+		found[key] = buf
+	}
+	return found, missing
+}
+
+// Stop implements Cache. There's nothing to release for an in-process cache.
+func (c *inMemoryCache) Stop() {}
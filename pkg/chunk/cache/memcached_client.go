@@ -15,6 +15,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/thanos-io/thanos/pkg/discovery/dns"
+	"golang.org/x/sync/singleflight"
 
 	"github.com/cortexproject/cortex/pkg/util"
 )
@@ -29,6 +30,8 @@ var (
 
 // MemcachedClient interface exists for mocking memcacheClient.
 type MemcachedClient interface {
+	Cache
+
 	GetMulti(keys []string) (map[string]*memcache.Item, error)
 	Set(item *memcache.Item) error
 }
@@ -38,6 +41,33 @@ type serverSelector interface {
 	SetServers(servers ...string) error
 }
 
+// selectorFactories maps the -memcached.selector flag's values to the
+// serverSelector they construct. "legacy" is memcache's own ServerList
+// (remainder-based key mapping); "jump" and "rendezvous" are the two
+// consistent-hashing options.
+var selectorFactories = map[string]func() serverSelector{
+	"legacy":     func() serverSelector { return &memcache.ServerList{} },
+	"jump":       func() serverSelector { return &MemcachedJumpHashSelector{} },
+	"rendezvous": func() serverSelector { return &MemcachedRendezvousSelector{} },
+}
+
+// newSelector builds the serverSelector cfg asks for. Selector takes
+// precedence when set; ConsistentHash is kept only for backward
+// compatibility with configs predating the -memcached.selector flag.
+func newSelector(cfg MemcachedClientConfig) (serverSelector, error) {
+	if cfg.Selector != "" {
+		factory, ok := selectorFactories[cfg.Selector]
+		if !ok {
+			return nil, fmt.Errorf("unrecognised memcached selector: %s", cfg.Selector)
+		}
+		return factory(), nil
+	}
+	if cfg.ConsistentHash {
+		return &MemcachedJumpHashSelector{}, nil
+	}
+	return &memcache.ServerList{}, nil
+}
+
 // memcachedClient is a memcache client that gets its server list from SRV
 // records, and periodically updates that ServerList.
 type memcachedClient struct {
@@ -54,6 +84,12 @@ type memcachedClient struct {
 	wait sync.WaitGroup
 
 	numServers prometheus.Gauge
+
+	// writeQueue holds SetAsync'd writes waiting to be flushed by the
+	// write-back worker pool, and singleflight coalesces concurrent
+	// GetMulti lookups for the same set of keys (not per individual key).
+	writeQueue   chan pendingWrite
+	singleflight singleflight.Group
 }
 
 // MemcachedClientConfig defines how a MemcachedClient should be constructed.
@@ -64,7 +100,11 @@ type MemcachedClientConfig struct {
 	Timeout        time.Duration `yaml:"timeout,omitempty"`
 	MaxIdleConns   int           `yaml:"max_idle_conns,omitempty"`
 	UpdateInterval time.Duration `yaml:"update_interval,omitempty"`
-	ConsistentHash bool          `yaml:"consistent_hash,omitempty"`
+	ConsistentHash bool          `yaml:"consistent_hash,omitempty"` // Deprecated: use Selector "jump" instead.
+	Selector       string        `yaml:"selector,omitempty"`
+
+	WriteBackBuffer      int `yaml:"write_back_buffer"`
+	WriteBackConcurrency int `yaml:"write_back_goroutines"`
 }
 
 // RegisterFlagsWithPrefix adds the flags required to config this to the given FlagSet
@@ -75,16 +115,17 @@ func (cfg *MemcachedClientConfig) RegisterFlagsWithPrefix(prefix, description st
 	f.IntVar(&cfg.MaxIdleConns, prefix+"memcached.max-idle-conns", 16, description+"Maximum number of idle connections in pool.")
 	f.DurationVar(&cfg.Timeout, prefix+"memcached.timeout", 100*time.Millisecond, description+"Maximum time to wait before giving up on memcached requests.")
 	f.DurationVar(&cfg.UpdateInterval, prefix+"memcached.update-interval", 1*time.Minute, description+"Period with which to poll DNS for memcache servers.")
-	f.BoolVar(&cfg.ConsistentHash, prefix+"memcached.consistent-hash", false, description+"Use consistent hashing to distribute to memcache servers.")
+	f.BoolVar(&cfg.ConsistentHash, prefix+"memcached.consistent-hash", false, description+"Use consistent hashing to distribute to memcache servers. Deprecated: use -"+prefix+"memcached.selector=jump instead.")
+	f.StringVar(&cfg.Selector, prefix+"memcached.selector", "", description+"Method used to select a memcached server for a key: legacy, jump or rendezvous. Defaults to jump if -"+prefix+"memcached.consistent-hash is set, legacy otherwise.")
+	cfg.registerAsyncFlags(prefix, description, f)
 }
 
 // NewMemcachedClient creates a new MemcacheClient that gets its server list
 // from SRV and updates the server list on a regular basis.
 func NewMemcachedClient(cfg MemcachedClientConfig, name string) MemcachedClient {
-	var selector serverSelector
-	if cfg.ConsistentHash {
-		selector = &MemcachedJumpHashSelector{}
-	} else {
+	selector, err := newSelector(cfg)
+	if err != nil {
+		level.Error(util.Logger).Log("msg", "invalid memcached selector, falling back to legacy", "err", err)
 		selector = &memcache.ServerList{}
 	}
 
@@ -102,22 +143,63 @@ func NewMemcachedClient(cfg MemcachedClientConfig, name string) MemcachedClient
 		quit:       make(chan struct{}),
 
 		numServers: memcacheServersDiscovered.WithLabelValues(name),
+
+		writeQueue: make(chan pendingWrite, cfg.WriteBackBuffer),
 	}
 
-	err := newClient.updateMemcacheServers()
+	err = newClient.updateMemcacheServers()
 	if err != nil {
 		level.Error(util.Logger).Log("msg", "error setting memcache servers to host", "host", cfg.Host, "err", err)
 	}
 
 	newClient.wait.Add(1)
 	go newClient.updateLoop(cfg.UpdateInterval)
+	newClient.startAsyncWriteBack(cfg.WriteBackConcurrency)
 	return newClient
 }
 
-// Stop the memcache client.
+// Stop the memcache client, draining any queued async writes first.
 func (c *memcachedClient) Stop() {
 	close(c.quit)
 	c.wait.Wait()
+	c.drainWriteBack(time.Now().Add(5 * time.Second))
+}
+
+// Store implements Cache. A per-key failure is logged and doesn't stop the
+// rest of the batch from being written, per Cache.Store's best-effort
+// contract.
+func (c *memcachedClient) Store(_ context.Context, keys []string, bufs [][]byte) error {
+	for i := range keys {
+		err := c.Set(&memcache.Item{
+			Key:   keys[i],
+			Value: bufs[i],
+		})
+		if err != nil {
+			level.Warn(util.Logger).Log("msg", "error writing to memcached", "key", keys[i], "err", err)
+		}
+	}
+	return nil
+}
+
+// Fetch implements Cache.
+func (c *memcachedClient) Fetch(_ context.Context, keys []string) (map[string][]byte, []string) {
+	items, err := c.GetMulti(keys)
+	if err != nil {
+		level.Error(util.Logger).Log("msg", "error fetching keys from memcached", "err", err)
+		return nil, keys
+	}
+
+	found := make(map[string][]byte, len(items))
+	missing := make([]string, 0, len(keys)-len(items))
+	for _, key := range keys {
+		item, ok := items[key]
+		if !ok {
+			missing = append(missing, key)
+			continue
+		}
+		found[key] = item.Value
+	}
+	return found, missing
 }
 
 func (c *memcachedClient) updateLoop(updateInterval time.Duration) {
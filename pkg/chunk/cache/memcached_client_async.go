@@ -0,0 +1,207 @@
+package cache
+
+import (
+	"flag"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/cespare/xxhash/v2"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/cortexproject/cortex/pkg/util"
+)
+
+var (
+	droppedWriteBacks = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "cortex",
+		Name:      "memcache_client_dropped_writes_total",
+		Help:      "Total count of async writes to memcached that were dropped because the write-back queue was full.",
+	})
+
+	writeBackLatency = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "cortex",
+		Name:      "memcache_client_writeback_latency_seconds",
+		Help:      "Time spent between an async write being enqueued and it being flushed to memcached.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	writeBackBatchSize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "cortex",
+		Name:      "memcache_client_writeback_batch_size",
+		Help:      "Number of items flushed to memcached together by a single write-back worker iteration.",
+		Buckets:   []float64{1, 2, 4, 8, 16, 32, 64, 128},
+	})
+)
+
+// pendingWrite is a SetAsync'd item still sitting in the write-back queue.
+type pendingWrite struct {
+	item     *memcache.Item
+	enqueued time.Time
+}
+
+// registerAsyncFlags adds the -memcached.write-buffer-size and
+// -memcached.write-concurrency flags to cfg.
+func (cfg *MemcachedClientConfig) registerAsyncFlags(prefix, description string, f *flag.FlagSet) {
+	f.IntVar(&cfg.WriteBackBuffer, prefix+"memcached.write-buffer-size", 10000, description+"Size of the async write-back queue, shared by all memcached servers.")
+	f.IntVar(&cfg.WriteBackConcurrency, prefix+"memcached.write-concurrency", 10, description+"Number of workers flushing the async write-back queue.")
+}
+
+// startAsyncWriteBack spins up cfg.WriteBackConcurrency workers that drain
+// c.writeQueue and flush it to memcached, and must be called once the
+// client's write queue has been created.
+func (c *memcachedClient) startAsyncWriteBack(concurrency int) {
+	for i := 0; i < concurrency; i++ {
+		c.wait.Add(1)
+		go c.writeBackLoop()
+	}
+}
+
+// SetAsync enqueues item to be written to memcached by a background worker,
+// rather than blocking the caller on the round trip. If the queue is full
+// the oldest queued write is dropped to make room, on the theory that a
+// stale cache write is worth less than a fresh one.
+func (c *memcachedClient) SetAsync(item *memcache.Item) {
+	pw := pendingWrite{item: item, enqueued: time.Now()}
+
+	select {
+	case c.writeQueue <- pw:
+		return
+	default:
+	}
+
+	// Queue is full: drop the oldest entry to make room, then try again.
+	select {
+	case <-c.writeQueue:
+		droppedWriteBacks.Inc()
+	default:
+	}
+
+	select {
+	case c.writeQueue <- pw:
+	default:
+		droppedWriteBacks.Inc()
+	}
+}
+
+// writeBackLoop flushes queued writes to memcached. Each iteration drains
+// whatever is immediately available (up to the queue's buffer) and then
+// writes those items one at a time, one Set round trip each; the items of a
+// batch are not pipelined into a single request.
+func (c *memcachedClient) writeBackLoop() {
+	defer c.wait.Done()
+
+	for {
+		var pw pendingWrite
+		select {
+		case pw = <-c.writeQueue:
+		case <-c.quit:
+			return
+		}
+
+		batch := []pendingWrite{pw}
+	drain:
+		for len(batch) < cap(c.writeQueue) {
+			select {
+			case pw := <-c.writeQueue:
+				batch = append(batch, pw)
+			default:
+				break drain
+			}
+		}
+
+		writeBackBatchSize.Observe(float64(len(batch)))
+		for _, pw := range batch {
+			writeBackLatency.Observe(time.Since(pw.enqueued).Seconds())
+			if err := c.Set(pw.item); err != nil {
+				level.Warn(util.Logger).Log("msg", "error async-writing to memcached", "err", err)
+			}
+		}
+	}
+}
+
+// drainWriteBack flushes whatever's left in the write queue, giving up once
+// deadline is reached.
+func (c *memcachedClient) drainWriteBack(deadline time.Time) {
+	for {
+		select {
+		case pw := <-c.writeQueue:
+			writeBackLatency.Observe(time.Since(pw.enqueued).Seconds())
+			if err := c.Set(pw.item); err != nil {
+				level.Warn(util.Logger).Log("msg", "error draining memcached write-back queue", "err", err)
+			}
+		default:
+			return
+		}
+		if time.Now().After(deadline) {
+			return
+		}
+	}
+}
+
+// GetMulti fetches all of keys from memcached in a single batched round
+// trip, as the embedded *memcache.Client's GetMulti already does. On top of
+// that, concurrent calls asking for the exact same set of keys (e.g. many
+// ingesters/queriers serving the same query at once) are coalesced via
+// singleflight into a single shared round trip, which is what protects
+// memcached from being stampeded.
+//
+// The dedup is keyed on the whole (sorted, de-duplicated) set of keys, not
+// per individual key: two concurrent calls for overlapping but different
+// key sets (e.g. [k1,k2,k3] and [k2,k3,k4]) still each fetch their own
+// batch rather than sharing the fetch of k2/k3. That narrower guarantee
+// covers the common stampede case -- many callers re-requesting the same
+// query's chunks at once -- without the complexity of per-key coalescing
+// against a batched backend.
+func (c *memcachedClient) GetMulti(keys []string) (map[string]*memcache.Item, error) {
+	unique := dedupeSorted(keys)
+	if len(unique) == 0 {
+		return map[string]*memcache.Item{}, nil
+	}
+
+	v, err, _ := c.singleflight.Do(batchGroupKey(unique), func() (interface{}, error) {
+		return c.Client.GetMulti(unique)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	items, _ := v.(map[string]*memcache.Item)
+	return items, nil
+}
+
+// batchGroupKey derives a singleflight group key from a sorted, de-duplicated
+// key set by hashing the keys, each followed by a NUL byte. A printable
+// delimiter such as a comma would not do: memcached keys may contain any
+// printable byte, which would let two different key sets collide on the
+// same group key, whereas gomemcache rejects keys containing NUL or any
+// other control character.
+func batchGroupKey(keys []string) string {
+	h := xxhash.New()
+	for _, k := range keys {
+		_, _ = h.WriteString(k)
+		_, _ = h.Write([]byte{0})
+	}
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// dedupeSorted returns the sorted, de-duplicated contents of keys, so that
+// two concurrent calls asking for the same set of keys in a different order
+// land on the same singleflight group key.
+func dedupeSorted(keys []string) []string {
+	seen := make(map[string]struct{}, len(keys))
+	unique := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+		unique = append(unique, k)
+	}
+	sort.Strings(unique)
+	return unique
+}
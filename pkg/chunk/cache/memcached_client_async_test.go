@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDedupeSorted(t *testing.T) {
+	got := dedupeSorted([]string{"c", "a", "b", "a", "c"})
+	want := []string{"a", "b", "c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestDedupeSorted_OrderIndependent(t *testing.T) {
+	a := dedupeSorted([]string{"k1", "k2", "k3"})
+	b := dedupeSorted([]string{"k3", "k1", "k2"})
+	if !reflect.DeepEqual(a, b) {
+		t.Fatalf("expected the same de-duplicated, sorted result regardless of input order: %v vs %v", a, b)
+	}
+}
+
+func TestBatchGroupKey_SameSetSameKey(t *testing.T) {
+	a := batchGroupKey(dedupeSorted([]string{"k1", "k2"}))
+	b := batchGroupKey(dedupeSorted([]string{"k2", "k1"}))
+	if a != b {
+		t.Fatalf("expected the same group key for the same key set: %q vs %q", a, b)
+	}
+}
+
+func TestBatchGroupKey_DoesNotCollideOnDelimiter(t *testing.T) {
+	// Without a collision-proof encoding, {"a,b", "c"} and {"a", "b,c"}
+	// would join to the same "a,b,c" string.
+	a := batchGroupKey(dedupeSorted([]string{"a,b", "c"}))
+	b := batchGroupKey(dedupeSorted([]string{"a", "b,c"}))
+	if a == b {
+		t.Fatalf("expected different key sets containing commas to produce different group keys")
+	}
+}
+
+func TestBatchGroupKey_DifferentSetsDiffer(t *testing.T) {
+	a := batchGroupKey(dedupeSorted([]string{"k1", "k2"}))
+	b := batchGroupKey(dedupeSorted([]string{"k1", "k3"}))
+	if a == b {
+		t.Fatalf("expected different key sets to produce different group keys")
+	}
+}
@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// MemcachedRendezvousSelector selects a memcached server for a given key
+// using Highest Random Weight (rendezvous) hashing: for every server we
+// compute a score from hash(key + "|" + server) and pick the server with
+// the highest score.
+//
+// Unlike MemcachedJumpHashSelector, removing or adding a server only
+// reshuffles the keys that hashed to that server, never keys hashed to any
+// other server, which makes it a better fit for heterogeneous server sets
+// and arbitrary removal (not just removal from the end of the list).
+type MemcachedRendezvousSelector struct {
+	mu      sync.RWMutex
+	servers []rendezvousServer
+}
+
+type rendezvousServer struct {
+	raw    string // as given in -memcached.addresses, without the @weight suffix
+	addr   net.Addr
+	weight float64
+}
+
+// SetServers implements serverSelector. Each entry in servers may carry an
+// optional "@weight" suffix (e.g. "10.0.0.1:11211@2"); servers without one
+// default to weight 1.
+func (s *MemcachedRendezvousSelector) SetServers(servers ...string) error {
+	parsed := make([]rendezvousServer, 0, len(servers))
+	for _, raw := range servers {
+		hostport, weight, err := parseWeightedServer(raw)
+		if err != nil {
+			return err
+		}
+
+		addr, err := resolveMemcachedAddr(hostport)
+		if err != nil {
+			return err
+		}
+
+		parsed = append(parsed, rendezvousServer{raw: hostport, addr: addr, weight: weight})
+	}
+
+	// Keep a stable order so ties break the same way regardless of the
+	// order servers were discovered in.
+	sort.Slice(parsed, func(i, j int) bool { return parsed[i].raw < parsed[j].raw })
+
+	s.mu.Lock()
+	s.servers = parsed
+	s.mu.Unlock()
+	return nil
+}
+
+// PickServer implements memcache.ServerSelector.
+func (s *MemcachedRendezvousSelector) PickServer(key string) (net.Addr, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.servers) == 0 {
+		return nil, fmt.Errorf("no servers configured")
+	}
+	if len(s.servers) == 1 {
+		return s.servers[0].addr, nil
+	}
+
+	var best rendezvousServer
+	bestScore := math.Inf(-1)
+	for _, srv := range s.servers {
+		score := rendezvousScore(key, srv.raw, srv.weight)
+		if score > bestScore || (score == bestScore && srv.raw < best.raw) {
+			best = srv
+			bestScore = score
+		}
+	}
+
+	return best.addr, nil
+}
+
+// Each implements memcache.ServerSelector.
+func (s *MemcachedRendezvousSelector) Each(f func(net.Addr) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, srv := range s.servers {
+		if err := f(srv.addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rendezvousScore computes the weighted HRW score of server for key: higher
+// is more preferred. Weighting follows the standard weighted-rendezvous
+// construction, score = weight / -ln(u), where u is a (0, 1) uniform
+// derived from hashing key and server together.
+func rendezvousScore(key, server string, weight float64) float64 {
+	h := xxhash.Sum64String(key + "|" + server)
+
+	// Map the top 52 bits of the hash into (0, 1), never exactly 0 or 1, so
+	// -ln(u) is always finite and positive and so is the score.
+	u := (float64(h>>12) + 0.5) / (1 << 52)
+	return weight / -math.Log(u)
+}
+
+// parseWeightedServer splits a "host:port@weight" entry into its address
+// and weight, defaulting to weight 1 when no "@weight" suffix is present.
+func parseWeightedServer(raw string) (hostport string, weight float64, err error) {
+	parts := strings.SplitN(raw, "@", 2)
+	if len(parts) == 1 {
+		return parts[0], 1, nil
+	}
+
+	weight, err = strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid weight in memcached address %q: %v", raw, err)
+	}
+	if weight <= 0 {
+		return "", 0, fmt.Errorf("invalid weight in memcached address %q: must be > 0", raw)
+	}
+	return parts[0], weight, nil
+}
+
+// resolveMemcachedAddr resolves a "host:port" entry to a net.Addr, the same
+// way memcache.ServerList resolves the addresses it is given.
+func resolveMemcachedAddr(hostport string) (net.Addr, error) {
+	return net.ResolveTCPAddr("tcp", hostport)
+}
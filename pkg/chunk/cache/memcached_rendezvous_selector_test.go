@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParseWeightedServer_DefaultWeight(t *testing.T) {
+	hostport, weight, err := parseWeightedServer("127.0.0.1:11211")
+	if err != nil {
+		t.Fatalf("parseWeightedServer: %v", err)
+	}
+	if hostport != "127.0.0.1:11211" || weight != 1 {
+		t.Fatalf("got (%q, %v), want (%q, 1)", hostport, weight, "127.0.0.1:11211")
+	}
+}
+
+func TestParseWeightedServer_ExplicitWeight(t *testing.T) {
+	hostport, weight, err := parseWeightedServer("127.0.0.1:11211@2.5")
+	if err != nil {
+		t.Fatalf("parseWeightedServer: %v", err)
+	}
+	if hostport != "127.0.0.1:11211" || weight != 2.5 {
+		t.Fatalf("got (%q, %v), want (%q, 2.5)", hostport, weight, "127.0.0.1:11211")
+	}
+}
+
+func TestParseWeightedServer_InvalidWeight(t *testing.T) {
+	if _, _, err := parseWeightedServer("127.0.0.1:11211@not-a-number"); err == nil {
+		t.Fatal("expected an error for a non-numeric weight")
+	}
+}
+
+func TestParseWeightedServer_NonPositiveWeightRejected(t *testing.T) {
+	for _, raw := range []string{"127.0.0.1:11211@0", "127.0.0.1:11211@-1"} {
+		if _, _, err := parseWeightedServer(raw); err == nil {
+			t.Fatalf("expected an error for weight <= 0 in %q", raw)
+		}
+	}
+}
+
+func TestRendezvousScore_Deterministic(t *testing.T) {
+	a := rendezvousScore("some-key", "127.0.0.1:11211", 1)
+	b := rendezvousScore("some-key", "127.0.0.1:11211", 1)
+	if a != b {
+		t.Fatalf("expected the same key/server/weight to always score the same, got %v and %v", a, b)
+	}
+}
+
+func TestRendezvousScore_HigherWeightScoresHigher(t *testing.T) {
+	low := rendezvousScore("some-key", "127.0.0.1:11211", 1)
+	high := rendezvousScore("some-key", "127.0.0.1:11211", 100)
+	if high <= low {
+		t.Fatalf("expected a much larger weight to produce a higher score: low=%v high=%v", low, high)
+	}
+}
+
+func TestRendezvousScore_DifferentServersScoreDifferently(t *testing.T) {
+	a := rendezvousScore("some-key", "127.0.0.1:11211", 1)
+	b := rendezvousScore("some-key", "127.0.0.1:11212", 1)
+	if a == b {
+		t.Fatalf("expected different servers to produce different scores for the same key and weight")
+	}
+}
+
+func TestMemcachedRendezvousSelector_PickServer_Consistent(t *testing.T) {
+	var s MemcachedRendezvousSelector
+	if err := s.SetServers("127.0.0.1:11211", "127.0.0.1:11212", "127.0.0.1:11213"); err != nil {
+		t.Fatalf("SetServers: %v", err)
+	}
+
+	first, err := s.PickServer("some-key")
+	if err != nil {
+		t.Fatalf("PickServer: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		got, err := s.PickServer("some-key")
+		if err != nil {
+			t.Fatalf("PickServer: %v", err)
+		}
+		if got.String() != first.String() {
+			t.Fatalf("expected PickServer to consistently pick the same server for the same key, got %q then %q", first, got)
+		}
+	}
+}
+
+func TestMemcachedRendezvousSelector_PickServer_NoServers(t *testing.T) {
+	var s MemcachedRendezvousSelector
+	if _, err := s.PickServer("some-key"); err == nil {
+		t.Fatal("expected an error when no servers are configured")
+	}
+}
+
+func TestMemcachedRendezvousSelector_PickServer_SingleServer(t *testing.T) {
+	var s MemcachedRendezvousSelector
+	if err := s.SetServers("127.0.0.1:11211"); err != nil {
+		t.Fatalf("SetServers: %v", err)
+	}
+
+	addr, err := s.PickServer("any-key")
+	if err != nil {
+		t.Fatalf("PickServer: %v", err)
+	}
+	if addr.String() != "127.0.0.1:11211" {
+		t.Fatalf("expected the only configured server, got %q", addr)
+	}
+}
+
+func TestMemcachedRendezvousSelector_SetServers_ParsesWeightSuffix(t *testing.T) {
+	var s MemcachedRendezvousSelector
+	if err := s.SetServers("127.0.0.1:11211@3"); err != nil {
+		t.Fatalf("SetServers: %v", err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.servers) != 1 || s.servers[0].weight != 3 {
+		t.Fatalf("expected a single server with weight 3, got %+v", s.servers)
+	}
+}
+
+func TestMemcachedRendezvousSelector_Each_VisitsAllServers(t *testing.T) {
+	var s MemcachedRendezvousSelector
+	if err := s.SetServers("127.0.0.1:11211", "127.0.0.1:11212"); err != nil {
+		t.Fatalf("SetServers: %v", err)
+	}
+
+	seen := map[string]bool{}
+	err := s.Each(func(addr net.Addr) error {
+		seen[addr.String()] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Each: %v", err)
+	}
+	if len(seen) != 2 {
+		t.Fatalf("expected Each to visit both servers, got %v", seen)
+	}
+}
@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/thanos-io/thanos/pkg/discovery/dns"
+
+	"github.com/cortexproject/cortex/pkg/util"
+)
+
+var redisServersDiscovered = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "cortex",
+	Name:      "redis_client_servers",
+	Help:      "The number of Redis servers discovered.",
+}, []string{"name"})
+
+// RedisConfig configures a redisCache.
+type RedisConfig struct {
+	Addresses      string        `yaml:"addresses"` // Thanos DNS Service Discovery format, same as memcached.addresses.
+	ClusterMode    bool          `yaml:"cluster_mode"`
+	Password       string        `yaml:"password"`
+	EnableTLS      bool          `yaml:"tls_enabled"`
+	Timeout        time.Duration `yaml:"timeout"`
+	UpdateInterval time.Duration `yaml:"update_interval"`
+}
+
+// RegisterFlagsWithPrefix adds the flags required to config this to the given FlagSet.
+func (cfg *RedisConfig) RegisterFlagsWithPrefix(prefix, description string, f *flag.FlagSet) {
+	f.StringVar(&cfg.Addresses, prefix+"redis.addresses", "", description+"Comma separated addresses list in Thanos DNS Service Discovery format: https://thanos.io/service-discovery.md/#dns-service-discovery")
+	f.BoolVar(&cfg.ClusterMode, prefix+"redis.cluster-mode", false, description+"Use Redis Cluster across the configured addresses.")
+	f.StringVar(&cfg.Password, prefix+"redis.password", "", description+"Password to use when connecting to Redis.")
+	f.BoolVar(&cfg.EnableTLS, prefix+"redis.tls-enabled", false, description+"Enable connecting to Redis with TLS.")
+	f.DurationVar(&cfg.Timeout, prefix+"redis.timeout", 100*time.Millisecond, description+"Maximum time to wait before giving up on Redis requests.")
+	f.DurationVar(&cfg.UpdateInterval, prefix+"redis.update-interval", time.Minute, description+"Period with which to poll DNS for Redis servers.")
+}
+
+// redisCache is a Cache backed by Redis (or Redis Cluster). Its servers are
+// resolved through Thanos-style DNS discovery when it is created and
+// re-resolved on a timer, but a re-resolved list only updates the servers
+// metric: the client keeps the addresses it was created with (in cluster
+// mode it reloads the cluster's slot layout on each tick). Outside cluster
+// mode only the first resolved address is used.
+type redisCache struct {
+	cfg      RedisConfig
+	client   redis.UniversalClient
+	provider *dns.Provider
+
+	addresses []string
+	quit      chan struct{}
+	wait      sync.WaitGroup
+
+	numServers prometheus.Gauge
+}
+
+// NewRedisCache creates a Cache backed by Redis.
+func NewRedisCache(cfg RedisConfig, name string) (Cache, error) {
+	var tlsConfig *tls.Config
+	if cfg.EnableTLS {
+		tlsConfig = &tls.Config{}
+	}
+
+	c := &redisCache{
+		cfg:        cfg,
+		addresses:  strings.Split(cfg.Addresses, ","),
+		provider:   dns.NewProvider(util.Logger, prometheus.DefaultRegisterer, dns.GolangResolverType),
+		quit:       make(chan struct{}),
+		numServers: redisServersDiscovered.WithLabelValues(name),
+	}
+
+	servers, err := c.resolveServers()
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.ClusterMode {
+		c.client = redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        servers,
+			Password:     cfg.Password,
+			DialTimeout:  cfg.Timeout,
+			ReadTimeout:  cfg.Timeout,
+			WriteTimeout: cfg.Timeout,
+			TLSConfig:    tlsConfig,
+		})
+	} else {
+		c.client = redis.NewClient(&redis.Options{
+			Addr:         firstOrEmpty(servers),
+			Password:     cfg.Password,
+			DialTimeout:  cfg.Timeout,
+			ReadTimeout:  cfg.Timeout,
+			WriteTimeout: cfg.Timeout,
+			TLSConfig:    tlsConfig,
+		})
+	}
+
+	c.wait.Add(1)
+	go c.updateLoop(cfg.UpdateInterval)
+	return c, nil
+}
+
+func firstOrEmpty(s []string) string {
+	if len(s) == 0 {
+		return ""
+	}
+	return s[0]
+}
+
+func (c *redisCache) resolveServers() ([]string, error) {
+	c.provider.Resolve(context.Background(), c.addresses)
+	servers := c.provider.Addresses()
+	c.numServers.Set(float64(len(servers)))
+	return servers, nil
+}
+
+func (c *redisCache) updateLoop(updateInterval time.Duration) {
+	defer c.wait.Done()
+	ticker := time.NewTicker(updateInterval)
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := c.resolveServers(); err != nil {
+				level.Warn(util.Logger).Log("msg", "error updating redis servers", "err", err)
+				continue
+			}
+			if cc, ok := c.client.(*redis.ClusterClient); ok {
+				cc.ReloadState(context.Background())
+			}
+		case <-c.quit:
+			ticker.Stop()
+			return
+		}
+	}
+}
+
+// Store implements Cache by pipelining one SET per key (not a single MSET).
+func (c *redisCache) Store(ctx context.Context, keys []string, bufs [][]byte) error {
+	pipe := c.client.Pipeline()
+	for i := range keys {
+		pipe.Set(ctx, keys[i], bufs[i], 0)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Fetch implements Cache by pipelining one GET per key (not a single MGET).
+func (c *redisCache) Fetch(ctx context.Context, keys []string) (map[string][]byte, []string) {
+	cmds := make([]*redis.StringCmd, len(keys))
+	pipe := c.client.Pipeline()
+	for i, key := range keys {
+		cmds[i] = pipe.Get(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		level.Error(util.Logger).Log("msg", "error fetching keys from redis", "err", err)
+	}
+
+	found := make(map[string][]byte, len(keys))
+	missing := make([]string, 0, len(keys))
+	for i, cmd := range cmds {
+		buf, err := cmd.Bytes()
+		if err != nil {
+			missing = append(missing, keys[i])
+			continue
+		}
+		found[keys[i]] = buf
+	}
+	return found, missing
+}
+
+// Stop implements Cache.
+func (c *redisCache) Stop() {
+	close(c.quit)
+	c.wait.Wait()
+	_ = c.client.Close()
+}
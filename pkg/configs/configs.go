@@ -79,6 +79,13 @@ func (c RulesConfig) Equal(o RulesConfig) bool {
 // once, not for every evaluation (or risk losing alert pending states). So
 // it's probably better to just return a set of rules.Rule here.
 func (c RulesConfig) Parse() (map[string][]rules.Rule, error) {
+	return c.parseGroups()
+}
+
+// parseGroups does the actual parsing work for Parse and ParseForShard,
+// keyed by the same "group;filename" key used to dedupe Prometheus group
+// names across rule files.
+func (c RulesConfig) parseGroups() (map[string][]rules.Rule, error) {
 	groups := map[string][]rules.Rule{}
 
 	for fn, content := range c {
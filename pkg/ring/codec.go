@@ -0,0 +1,39 @@
+package ring
+
+import (
+	"encoding/json"
+
+	"github.com/cortexproject/cortex/pkg/ring/kv"
+)
+
+// descCodec is the kv.Codec a Ring's KV store needs to round-trip Desc
+// values; JSON keeps the wire format human-readable for operators poking at
+// the store directly (e.g. via the Consul UI).
+type descCodec struct{}
+
+// Marshal implements kv.Codec.
+func (descCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v.(*Desc))
+}
+
+// Unmarshal implements kv.Codec.
+func (descCodec) Unmarshal(data []byte) (interface{}, error) {
+	desc := NewDesc()
+	if err := json.Unmarshal(data, desc); err != nil {
+		return nil, err
+	}
+	return desc, nil
+}
+
+// NewKVStore creates the kv.Client backing a ring, pre-configured with the
+// codec needed to round-trip Desc values -- kv.Config.Codec has no default,
+// so building a Client via kv.NewClient directly without going through here
+// would nil-pointer-dereference on the first Get/CAS. A Ring and the
+// Lifecyclers that register into it must all share the single Client this
+// returns, since the in-memory and memberlist backends hold their state
+// locally rather than in a shared external store.
+func NewKVStore(cfg Config) (kv.Client, error) {
+	kvCfg := cfg.KVStore
+	kvCfg.Codec = descCodec{}
+	return kv.NewClient(kvCfg)
+}
@@ -0,0 +1,32 @@
+package ring
+
+import "testing"
+
+func TestDescCodec_RoundTrip(t *testing.T) {
+	desc := NewDesc()
+	desc.Instances["instance-1"] = InstanceDesc{
+		Addr:      "instance-1",
+		State:     ACTIVE,
+		Tokens:    []uint32{1, 2, 3},
+		Timestamp: 12345,
+	}
+
+	var codec descCodec
+	buf, err := codec.Marshal(desc)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	v, err := codec.Unmarshal(buf)
+	if err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	got, ok := v.(*Desc)
+	if !ok {
+		t.Fatalf("expected *Desc, got %T", v)
+	}
+	if got.Instances["instance-1"].Addr != "instance-1" || got.Instances["instance-1"].Timestamp != 12345 {
+		t.Fatalf("round trip lost data: %+v", got.Instances["instance-1"])
+	}
+}
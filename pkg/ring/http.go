@@ -0,0 +1,65 @@
+package ring
+
+import (
+	"html/template"
+	"net/http"
+	"sort"
+	"time"
+)
+
+var ringPageTemplate = template.Must(template.New("ring").Parse(`
+<!DOCTYPE html>
+<html>
+	<head><title>Ring Status</title></head>
+	<body>
+		<h1>Ring Status</h1>
+		<table border="1">
+			<thead>
+				<tr><th>Instance</th><th>State</th><th>Age</th><th>Tokens</th></tr>
+			</thead>
+			<tbody>
+				{{ range .Instances }}
+				<tr>
+					<td>{{ .Addr }}</td>
+					<td>{{ .State }}</td>
+					<td>{{ .Age }}</td>
+					<td>{{ .NumTokens }}</td>
+				</tr>
+				{{ end }}
+			</tbody>
+		</table>
+	</body>
+</html>
+`))
+
+type ringPageInstance struct {
+	Addr      string
+	State     string
+	Age       time.Duration
+	NumTokens int
+}
+
+// ServeHTTP renders a simple /ring page listing every instance and its
+// current state, for operators checking that a scale up/down has settled.
+//
+// Nothing in this tree registers this handler on a server or calls it from
+// a test.
+func (r *Ring) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	desc := r.snapshot()
+
+	instances := make([]ringPageInstance, 0, len(desc.Instances))
+	for _, inst := range desc.Instances {
+		instances = append(instances, ringPageInstance{
+			Addr:      inst.Addr,
+			State:     inst.State.String(),
+			Age:       time.Since(time.Unix(inst.Timestamp, 0)).Round(time.Second),
+			NumTokens: len(inst.Tokens),
+		})
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].Addr < instances[j].Addr })
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := ringPageTemplate.Execute(w, struct{ Instances []ringPageInstance }{instances}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,130 @@
+package kv
+
+import (
+	"flag"
+	"net/http"
+	"time"
+
+	consul "github.com/hashicorp/consul/api"
+
+	"github.com/go-kit/kit/log/level"
+
+	"github.com/cortexproject/cortex/pkg/util"
+)
+
+// ConsulConfig configures a connection to Consul.
+type ConsulConfig struct {
+	Host              string        `yaml:"host"`
+	ACLToken          string        `yaml:"acl_token"`
+	HTTPClientTimeout time.Duration `yaml:"http_client_timeout"`
+}
+
+// RegisterFlagsWithPrefix adds the flags required to config this to the given FlagSet.
+func (cfg *ConsulConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.Host, prefix+"consul.hostname", "localhost:8500", "Hostname and port of Consul.")
+	f.StringVar(&cfg.ACLToken, prefix+"consul.acl-token", "", "ACL Token used to interact with Consul.")
+	f.DurationVar(&cfg.HTTPClientTimeout, prefix+"consul.client-timeout", 20*time.Second, "HTTP timeout when talking to Consul")
+}
+
+// consulClient implements Client on top of Consul's key-value store.
+type consulClient struct {
+	kv    *consul.KV
+	codec Codec
+}
+
+// NewConsulClient creates a Client backed by Consul.
+func NewConsulClient(cfg ConsulConfig, codec Codec) (Client, error) {
+	client, err := consul.NewClient(&consul.Config{
+		Address:    cfg.Host,
+		Token:      cfg.ACLToken,
+		HttpClient: &http.Client{Timeout: cfg.HTTPClientTimeout},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &consulClient{kv: client.KV(), codec: codec}, nil
+}
+
+func (c *consulClient) Get(key string) (interface{}, error) {
+	kvp, _, err := c.kv.Get(key, nil)
+	if err != nil {
+		return nil, err
+	}
+	if kvp == nil {
+		return nil, nil
+	}
+	return c.codec.Unmarshal(kvp.Value)
+}
+
+// CAS retries a Consul check-and-set until it succeeds or f declines a retry.
+func (c *consulClient) CAS(key string, f func(in interface{}) (out interface{}, retry bool, err error)) error {
+	for {
+		kvp, _, err := c.kv.Get(key, nil)
+		if err != nil {
+			return err
+		}
+
+		var current interface{}
+		index := uint64(0)
+		if kvp != nil {
+			index = kvp.ModifyIndex
+			current, err = c.codec.Unmarshal(kvp.Value)
+			if err != nil {
+				return err
+			}
+		}
+
+		out, retry, err := f(current)
+		if err != nil {
+			return err
+		}
+		if out == nil {
+			return nil
+		}
+
+		buf, err := c.codec.Marshal(out)
+		if err != nil {
+			return err
+		}
+
+		ok, _, err := c.kv.CAS(&consul.KVPair{Key: key, Value: buf, ModifyIndex: index}, nil)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		if !retry {
+			return nil
+		}
+		level.Debug(util.Logger).Log("msg", "retrying consul CAS", "key", key)
+	}
+}
+
+func (c *consulClient) WatchKey(key string, f func(interface{}) bool) {
+	var lastIndex uint64
+	for {
+		kvp, meta, err := c.kv.Get(key, &consul.QueryOptions{WaitIndex: lastIndex, WaitTime: 10 * time.Second})
+		if err != nil {
+			level.Error(util.Logger).Log("msg", "error watching consul key", "key", key, "err", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		if meta != nil {
+			lastIndex = meta.LastIndex
+		}
+		if kvp == nil {
+			continue
+		}
+
+		v, err := c.codec.Unmarshal(kvp.Value)
+		if err != nil {
+			level.Error(util.Logger).Log("msg", "error decoding consul value", "key", key, "err", err)
+			continue
+		}
+		if !f(v) {
+			return
+		}
+	}
+}
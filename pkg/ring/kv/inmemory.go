@@ -0,0 +1,57 @@
+package kv
+
+import "sync"
+
+// inMemoryClient implements Client with a plain mutex-guarded map. Useful
+// for single-binary deployments and tests, where there is only ever one
+// process and no need to coordinate across a network.
+type inMemoryClient struct {
+	codec Codec
+
+	mtx      sync.Mutex
+	values   map[string]interface{}
+	watchers map[string][]func(interface{}) bool
+}
+
+// NewInMemoryClient creates a Client backed by an in-process map.
+func NewInMemoryClient(codec Codec) Client {
+	return &inMemoryClient{
+		codec:    codec,
+		values:   map[string]interface{}{},
+		watchers: map[string][]func(interface{}) bool{},
+	}
+}
+
+func (c *inMemoryClient) Get(key string) (interface{}, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.values[key], nil
+}
+
+func (c *inMemoryClient) CAS(key string, f func(in interface{}) (out interface{}, retry bool, err error)) error {
+	c.mtx.Lock()
+	current := c.values[key]
+	out, _, err := f(current)
+	if err != nil {
+		c.mtx.Unlock()
+		return err
+	}
+	if out == nil {
+		c.mtx.Unlock()
+		return nil
+	}
+	c.values[key] = out
+	watchers := append([]func(interface{}) bool{}, c.watchers[key]...)
+	c.mtx.Unlock()
+
+	for _, w := range watchers {
+		w(out)
+	}
+	return nil
+}
+
+func (c *inMemoryClient) WatchKey(key string, f func(interface{}) bool) {
+	c.mtx.Lock()
+	c.watchers[key] = append(c.watchers[key], f)
+	c.mtx.Unlock()
+}
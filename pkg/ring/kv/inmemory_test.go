@@ -0,0 +1,95 @@
+package kv
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+type stringCodec struct{}
+
+func (stringCodec) Marshal(v interface{}) ([]byte, error)      { return []byte(v.(string)), nil }
+func (stringCodec) Unmarshal(data []byte) (interface{}, error) { return string(data), nil }
+
+func TestInMemoryClient_CAS_AppliesFToCurrentValue(t *testing.T) {
+	c := NewInMemoryClient(stringCodec{})
+
+	err := c.CAS("key", func(in interface{}) (interface{}, bool, error) {
+		if in != nil {
+			t.Fatalf("expected nil for a fresh key, got %v", in)
+		}
+		return "v1", true, nil
+	})
+	if err != nil {
+		t.Fatalf("CAS: %v", err)
+	}
+
+	err = c.CAS("key", func(in interface{}) (interface{}, bool, error) {
+		if in != "v1" {
+			t.Fatalf("expected v1, got %v", in)
+		}
+		return "v2", true, nil
+	})
+	if err != nil {
+		t.Fatalf("CAS: %v", err)
+	}
+
+	v, err := c.Get("key")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if v != "v2" {
+		t.Fatalf("expected v2, got %v", v)
+	}
+}
+
+func TestInMemoryClient_CAS_NilOutLeavesValueUnchanged(t *testing.T) {
+	c := NewInMemoryClient(stringCodec{})
+
+	_ = c.CAS("key", func(in interface{}) (interface{}, bool, error) { return "v1", true, nil })
+
+	err := c.CAS("key", func(in interface{}) (interface{}, bool, error) { return nil, false, nil })
+	if err != nil {
+		t.Fatalf("CAS: %v", err)
+	}
+
+	v, err := c.Get("key")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if v != "v1" {
+		t.Fatalf("expected v1 to be left untouched, got %v", v)
+	}
+}
+
+func TestInMemoryClient_CAS_PropagatesError(t *testing.T) {
+	c := NewInMemoryClient(stringCodec{})
+	wantErr := errors.New("boom")
+
+	err := c.CAS("key", func(in interface{}) (interface{}, bool, error) { return nil, false, wantErr })
+	if err != wantErr {
+		t.Fatalf("expected %v, got %v", wantErr, err)
+	}
+}
+
+func TestInMemoryClient_WatchKey_NotifiedOnCAS(t *testing.T) {
+	c := NewInMemoryClient(stringCodec{})
+
+	var mtx sync.Mutex
+	var got []interface{}
+	c.WatchKey("key", func(v interface{}) bool {
+		mtx.Lock()
+		got = append(got, v)
+		mtx.Unlock()
+		return true
+	})
+
+	_ = c.CAS("key", func(in interface{}) (interface{}, bool, error) { return "v1", true, nil })
+	_ = c.CAS("key", func(in interface{}) (interface{}, bool, error) { return "v2", true, nil })
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	if len(got) != 2 || got[0] != "v1" || got[1] != "v2" {
+		t.Fatalf("expected watcher to observe [v1 v2], got %v", got)
+	}
+}
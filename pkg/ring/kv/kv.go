@@ -0,0 +1,77 @@
+// Package kv provides a small abstraction over the key-value stores that
+// Cortex rings can use to exchange membership state: Consul or memberlist's
+// gossip-based store.
+package kv
+
+import (
+	"flag"
+	"fmt"
+)
+
+// Client is a generic key-value store client, modelled after Consul's
+// check-and-set semantics so the same interface can be backed by either
+// Consul or memberlist.
+type Client interface {
+	// CAS atomically modifies the value at key. f is called with the
+	// current value (nil if the key doesn't exist yet) and returns the new
+	// value to write, or retry=true to re-read and call f again on a
+	// conflicting write.
+	CAS(key string, f func(in interface{}) (out interface{}, retry bool, err error)) error
+
+	// Get returns the current value of key, or nil if it doesn't exist.
+	Get(key string) (interface{}, error)
+
+	// WatchKey calls f whenever the value at key changes. f returns false
+	// to stop watching.
+	WatchKey(key string, f func(interface{}) bool)
+}
+
+// Config configures which backend a ring uses to store its membership
+// state, and how to reach it.
+type Config struct {
+	Store      string           `yaml:"store"`
+	Consul     ConsulConfig     `yaml:"consul"`
+	Memberlist MemberlistConfig `yaml:"memberlist"`
+
+	// Codec describes how values are marshalled before being written to the
+	// store. Left unset by default; callers must set it before use.
+	Codec Codec `yaml:"-"`
+}
+
+// RegisterFlagsWithPrefix adds the flags required to config this to the given FlagSet.
+func (cfg *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.Store, prefix+"store", "consul", "Backend storage to use for the ring. Supported values are: consul, memberlist, inmemory.")
+	cfg.Consul.RegisterFlagsWithPrefix(prefix, f)
+	cfg.Memberlist.RegisterFlagsWithPrefix(prefix, f)
+}
+
+// NewClient creates a new Client from the given Config.
+func NewClient(cfg Config) (Client, error) {
+	switch cfg.Store {
+	case "consul":
+		return NewConsulClient(cfg.Consul, cfg.Codec)
+	case "memberlist":
+		return NewMemberlistClient(cfg.Memberlist, cfg.Codec)
+	case "inmemory":
+		return NewInMemoryClient(cfg.Codec), nil
+	default:
+		return nil, fmt.Errorf("invalid KV store type: %s", cfg.Store)
+	}
+}
+
+// Codec marshals and unmarshals the values a ring stores. Merging
+// concurrently-updated values is not part of a Codec: the memberlist Client
+// merges values that implement Mergeable.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte) (interface{}, error)
+}
+
+// Mergeable is implemented by values that know how to combine themselves
+// with a concurrently-modified copy of the same value. The memberlist
+// Client uses this to merge a gossiped update into its local copy instead
+// of one overwriting the other, since memberlist only gives us eventual,
+// not linearizable, consistency.
+type Mergeable interface {
+	Merge(other interface{}) interface{}
+}
@@ -0,0 +1,183 @@
+package kv
+
+import (
+	"flag"
+	"sync"
+
+	"github.com/hashicorp/memberlist"
+
+	"github.com/go-kit/kit/log/level"
+
+	"github.com/cortexproject/cortex/pkg/util"
+)
+
+// MemberlistConfig configures a gossip-based KV store on top of memberlist.
+// Unlike Consul it has no single point of failure, at the cost of eventual
+// (rather than strong) consistency between watchers.
+type MemberlistConfig struct {
+	JoinMembers []string `yaml:"join_members"`
+	BindPort    int      `yaml:"bind_port"`
+}
+
+// RegisterFlagsWithPrefix adds the flags required to config this to the given FlagSet.
+func (cfg *MemberlistConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.IntVar(&cfg.BindPort, prefix+"memberlist.bind-port", 7946, "Port to listen on for gossip memberlist messages.")
+}
+
+// memberlistClient implements Client by gossiping the whole value for a
+// single well-known key around the cluster and keeping a local copy. This
+// is sized for ring-sized values (tens of instances), not general-purpose
+// key-value storage.
+type memberlistClient struct {
+	ml    *memberlist.Memberlist
+	queue *memberlist.TransmitLimitedQueue
+	codec Codec
+
+	mtx      sync.Mutex
+	values   map[string]interface{}
+	watchers map[string][]func(interface{}) bool
+}
+
+type broadcast struct {
+	key string
+	msg []byte
+}
+
+func (b *broadcast) Invalidates(memberlist.Broadcast) bool { return false }
+func (b *broadcast) Message() []byte                       { return b.msg }
+func (b *broadcast) Finished()                             {}
+
+type memberlistDelegate struct{ c *memberlistClient }
+
+func (d *memberlistDelegate) NodeMeta(limit int) []byte              { return nil }
+func (d *memberlistDelegate) LocalState(join bool) []byte            { return nil }
+func (d *memberlistDelegate) MergeRemoteState(buf []byte, join bool) {}
+func (d *memberlistDelegate) GetBroadcasts(overhead, limit int) [][]byte {
+	return d.c.queue.GetBroadcasts(overhead, limit)
+}
+
+// NotifyMsg handles a gossiped key/value update broadcast by another node.
+// The wire format is just "<key>\n<encoded value>"; the ring only ever
+// gossips one key, so this keeps the protocol trivial. The decoded value is
+// merged into (rather than overwriting) the local copy, so a node that
+// momentarily missed some other node's update doesn't drop it.
+func (d *memberlistDelegate) NotifyMsg(msg []byte) {
+	key, payload := splitMessage(msg)
+	v, err := d.c.codec.Unmarshal(payload)
+	if err != nil {
+		level.Error(util.Logger).Log("msg", "error decoding memberlist message", "err", err)
+		return
+	}
+
+	d.c.mtx.Lock()
+	merged := mergeValue(d.c.values[key], v)
+	d.c.values[key] = merged
+	watchers := append([]func(interface{}) bool{}, d.c.watchers[key]...)
+	d.c.mtx.Unlock()
+
+	for _, f := range watchers {
+		f(merged)
+	}
+}
+
+// mergeValue combines update with current when update implements Mergeable,
+// so concurrent changes from different nodes don't clobber each other; a
+// non-Mergeable value just replaces the one it's overwriting, as before.
+func mergeValue(current, update interface{}) interface{} {
+	m, ok := update.(Mergeable)
+	if !ok || current == nil {
+		return update
+	}
+	return m.Merge(current)
+}
+
+// NewMemberlistClient creates a Client backed by a memberlist gossip ring.
+func NewMemberlistClient(cfg MemberlistConfig, codec Codec) (Client, error) {
+	c := &memberlistClient{
+		codec:    codec,
+		values:   map[string]interface{}{},
+		watchers: map[string][]func(interface{}) bool{},
+	}
+	c.queue = &memberlist.TransmitLimitedQueue{RetransmitMult: 3, NumNodes: func() int { return c.ml.NumMembers() }}
+
+	mlConfig := memberlist.DefaultLANConfig()
+	mlConfig.BindPort = cfg.BindPort
+	mlConfig.Delegate = &memberlistDelegate{c: c}
+
+	ml, err := memberlist.Create(mlConfig)
+	if err != nil {
+		return nil, err
+	}
+	c.ml = ml
+
+	if len(cfg.JoinMembers) > 0 {
+		if _, err := ml.Join(cfg.JoinMembers); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func (c *memberlistClient) Get(key string) (interface{}, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.values[key], nil
+}
+
+// CAS applies f to the local value and gossips the result; memberlist gives
+// us eventual rather than linearizable consistency, so unlike the Consul
+// client this never needs to retry against a conflicting remote write.
+// Instead, the value f returns is merged into whatever the local copy has
+// become by the time CAS is ready to store it (it may have moved on from
+// the "in" that f saw, via a concurrent CAS or a gossiped update), so two
+// instances registering at the same time don't erase each other.
+func (c *memberlistClient) CAS(key string, f func(in interface{}) (out interface{}, retry bool, err error)) error {
+	c.mtx.Lock()
+	current := c.values[key]
+	c.mtx.Unlock()
+
+	out, _, err := f(current)
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+
+	c.mtx.Lock()
+	merged := mergeValue(c.values[key], out)
+	c.values[key] = merged
+	watchers := append([]func(interface{}) bool{}, c.watchers[key]...)
+	c.mtx.Unlock()
+
+	for _, w := range watchers {
+		w(merged)
+	}
+
+	buf, err := c.codec.Marshal(merged)
+	if err != nil {
+		return err
+	}
+	c.queue.QueueBroadcast(&broadcast{key: key, msg: joinMessage(key, buf)})
+	return nil
+}
+
+func (c *memberlistClient) WatchKey(key string, f func(interface{}) bool) {
+	c.mtx.Lock()
+	c.watchers[key] = append(c.watchers[key], f)
+	c.mtx.Unlock()
+}
+
+func joinMessage(key string, payload []byte) []byte {
+	return append([]byte(key+"\n"), payload...)
+}
+
+func splitMessage(msg []byte) (key string, payload []byte) {
+	for i, b := range msg {
+		if b == '\n' {
+			return string(msg[:i]), msg[i+1:]
+		}
+	}
+	return "", msg
+}
@@ -0,0 +1,70 @@
+package ring
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/ring/kv"
+)
+
+type testCodec struct{}
+
+func (testCodec) Marshal(v interface{}) ([]byte, error)      { return nil, nil }
+func (testCodec) Unmarshal(data []byte) (interface{}, error) { return nil, nil }
+
+func waitForState(t *testing.T, store kv.Client, key, addr string, want InstanceState) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		v, err := store.Get(key)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if desc, ok := v.(*Desc); ok {
+			if inst, ok := desc.Instances[addr]; ok && inst.State == want {
+				return
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("instance %s never reached state %s", addr, want)
+}
+
+func TestLifecycler_StateTransitions(t *testing.T) {
+	store := kv.NewInMemoryClient(testCodec{})
+
+	cfg := LifecyclerConfig{
+		Addr:            "instance-1",
+		NumTokens:       4,
+		HeartbeatPeriod: time.Hour, // don't race the explicit heartbeat below
+	}
+
+	l, err := NewLifecycler(cfg, store, "ring")
+	if err != nil {
+		t.Fatalf("NewLifecycler: %v", err)
+	}
+
+	// JoinAfter defaults to 0, so loop() flips straight to ACTIVE.
+	waitForState(t, store, "ring", "instance-1", ACTIVE)
+
+	if err := l.heartbeat(); err != nil {
+		t.Fatalf("heartbeat: %v", err)
+	}
+
+	if err := l.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	v, err := store.Get("ring")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	desc, ok := v.(*Desc)
+	if !ok {
+		t.Fatalf("expected *Desc, got %T", v)
+	}
+	if _, ok := desc.Instances["instance-1"]; ok {
+		t.Fatalf("expected instance-1 to be removed from the ring after Shutdown")
+	}
+}
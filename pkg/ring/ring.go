@@ -0,0 +1,220 @@
+// Package ring implements a consistent-hash ring that Cortex components use
+// to shard ownership of work (for example rule group evaluation) across a
+// set of replicas, and to re-shard that ownership cleanly as replicas join
+// or leave.
+package ring
+
+import (
+	"errors"
+	"flag"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/cortexproject/cortex/pkg/ring/kv"
+)
+
+// ErrEmptyRing is returned when trying to look up an owner in a ring that
+// has no healthy members.
+var ErrEmptyRing = errors.New("empty ring")
+
+// InstanceState is the lifecycle state of a single ring member.
+type InstanceState int
+
+// Possible states of a ring member, following the usual join/leave
+// lifecycle: an instance registers its tokens as JOINING, flips to ACTIVE
+// once it is ready to serve, and announces LEAVING while it drains
+// in-flight work before removing itself from the ring.
+const (
+	JOINING InstanceState = iota
+	ACTIVE
+	LEAVING
+)
+
+func (s InstanceState) String() string {
+	switch s {
+	case JOINING:
+		return "JOINING"
+	case ACTIVE:
+		return "ACTIVE"
+	case LEAVING:
+		return "LEAVING"
+	default:
+		return "Unknown"
+	}
+}
+
+// InstanceDesc describes a single instance registered in the ring.
+type InstanceDesc struct {
+	Addr      string        `json:"addr"`
+	State     InstanceState `json:"state"`
+	Tokens    []uint32      `json:"tokens"`
+	Timestamp int64         `json:"timestamp"` // unix seconds of the last heartbeat
+}
+
+// Desc is the full state of the ring, as stored in the KV store.
+type Desc struct {
+	Instances map[string]InstanceDesc `json:"instances"`
+}
+
+// NewDesc returns an empty ring description.
+func NewDesc() *Desc {
+	return &Desc{Instances: map[string]InstanceDesc{}}
+}
+
+// Merge implements kv.Mergeable, so that a gossip-based Client (memberlist)
+// can combine two concurrently-updated Descs instead of one clobbering the
+// other. Each instance address is merged independently, last-write-wins by
+// Timestamp; an address present in only one side is carried over untouched
+// so a concurrent update that never observed it can't make it vanish.
+func (d *Desc) Merge(other interface{}) interface{} {
+	o, ok := other.(*Desc)
+	if !ok || o == nil {
+		return d
+	}
+
+	merged := NewDesc()
+	for addr, inst := range d.Instances {
+		merged.Instances[addr] = inst
+	}
+	for addr, inst := range o.Instances {
+		existing, ok := merged.Instances[addr]
+		if !ok || inst.Timestamp > existing.Timestamp {
+			merged.Instances[addr] = inst
+		}
+	}
+	return merged
+}
+
+// tokens returns all tokens in the ring sorted ascending, along with the
+// owning instance address for each token.
+func (d *Desc) tokens() ([]uint32, map[uint32]string) {
+	owners := map[uint32]string{}
+	for addr, inst := range d.Instances {
+		for _, t := range inst.Tokens {
+			owners[t] = addr
+		}
+	}
+	tokens := make([]uint32, 0, len(owners))
+	for t := range owners {
+		tokens = append(tokens, t)
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i] < tokens[j] })
+	return tokens, owners
+}
+
+// Config configures a Ring.
+type Config struct {
+	KVStore          kv.Config     `yaml:"kvstore"`
+	HeartbeatTimeout time.Duration `yaml:"heartbeat_timeout"`
+}
+
+// RegisterFlags adds the flags required to config this to the given FlagSet.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	cfg.KVStore.RegisterFlagsWithPrefix("ruler.ring.", f)
+	f.DurationVar(&cfg.HeartbeatTimeout, "ruler.ring.heartbeat-timeout", time.Minute, "The heartbeat timeout after which ring members are considered unhealthy.")
+}
+
+// Ring holds a consistent view of the ring's state, kept up to date by
+// watching the KV store, and answers ownership queries against it.
+type Ring struct {
+	key              string
+	store            kv.Client
+	heartbeatTimeout time.Duration
+
+	mtx  sync.RWMutex
+	desc Desc
+
+	numMembers prometheus.Gauge
+}
+
+// New creates a new Ring that watches key in the given KV store. Instances
+// whose last heartbeat is older than cfg.HeartbeatTimeout are treated as
+// dead and skipped by Get/Owns, regardless of their last known State.
+func New(cfg Config, store kv.Client, key, name string) *Ring {
+	r := &Ring{
+		key:              key,
+		store:            store,
+		heartbeatTimeout: cfg.HeartbeatTimeout,
+		desc:             *NewDesc(),
+		numMembers: promauto.NewGauge(prometheus.GaugeOpts{
+			Namespace:   "cortex",
+			Name:        "ring_members",
+			Help:        "Number of members in the ring.",
+			ConstLabels: prometheus.Labels{"name": name},
+		}),
+	}
+
+	go r.loop()
+	return r
+}
+
+// loop watches the KV store for changes and keeps the in-memory copy of the
+// ring up to date.
+func (r *Ring) loop() {
+	r.store.WatchKey(r.key, func(v interface{}) bool {
+		d, ok := v.(*Desc)
+		if !ok || d == nil {
+			return true
+		}
+
+		r.mtx.Lock()
+		r.desc = *d
+		r.numMembers.Set(float64(len(d.Instances)))
+		r.mtx.Unlock()
+		return true
+	})
+}
+
+// Get returns the address of the healthy instance that owns the given hash,
+// walking clockwise around the ring from hash until it finds a token owned
+// by an ACTIVE instance that has heartbeated within HeartbeatTimeout.
+func (r *Ring) Get(hash uint32) (string, error) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	tokens, owners := r.desc.tokens()
+	if len(tokens) == 0 {
+		return "", ErrEmptyRing
+	}
+
+	i := sort.Search(len(tokens), func(i int) bool { return tokens[i] >= hash })
+	now := time.Now()
+
+	// Walk forward (wrapping around) until we find a token owned by an
+	// ACTIVE, live instance, skipping JOINING/LEAVING instances (so that
+	// ownership only transfers once a replica is actually ready to serve)
+	// and instances that stopped heartbeating without a clean Shutdown.
+	for n := 0; n < len(tokens); n++ {
+		addr := owners[tokens[(i+n)%len(tokens)]]
+		inst, ok := r.desc.Instances[addr]
+		if !ok || inst.State != ACTIVE {
+			continue
+		}
+		if r.heartbeatTimeout > 0 && now.Sub(time.Unix(inst.Timestamp, 0)) > r.heartbeatTimeout {
+			continue
+		}
+		return addr, nil
+	}
+
+	return "", ErrEmptyRing
+}
+
+// Owns returns true if instanceAddr is the current ACTIVE owner of hash.
+func (r *Ring) Owns(hash uint32, instanceAddr string) (bool, error) {
+	owner, err := r.Get(hash)
+	if err != nil {
+		return false, err
+	}
+	return owner == instanceAddr, nil
+}
+
+// snapshot returns a copy of the current ring state, for rendering.
+func (r *Ring) snapshot() Desc {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	return r.desc
+}
@@ -0,0 +1,102 @@
+package ring
+
+import (
+	"testing"
+	"time"
+)
+
+func instance(addr string, state InstanceState, age time.Duration) InstanceDesc {
+	return InstanceDesc{
+		Addr:      addr,
+		State:     state,
+		Tokens:    []uint32{hashToken(addr)},
+		Timestamp: time.Now().Add(-age).Unix(),
+	}
+}
+
+// hashToken derives a single deterministic token per address, just so each
+// test instance owns a distinct, known point on the ring.
+func hashToken(addr string) uint32 {
+	var h uint32
+	for _, b := range []byte(addr) {
+		h = h*31 + uint32(b)
+	}
+	return h
+}
+
+func newTestRing(instances ...InstanceDesc) *Ring {
+	desc := NewDesc()
+	for _, inst := range instances {
+		desc.Instances[inst.Addr] = inst
+	}
+	return &Ring{
+		key:              "ring",
+		heartbeatTimeout: time.Minute,
+		desc:             *desc,
+	}
+}
+
+func TestRing_Get_SkipsNonActiveAndDeadInstances(t *testing.T) {
+	r := newTestRing(
+		instance("joining", JOINING, 0),
+		instance("leaving", LEAVING, 0),
+		instance("dead", ACTIVE, 5*time.Minute),
+		instance("healthy", ACTIVE, 0),
+	)
+
+	addr, err := r.Get(hashToken("healthy"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if addr != "healthy" {
+		t.Fatalf("expected healthy, got %s", addr)
+	}
+
+	// Looking up any of the other instances' own tokens should still land
+	// on the only live, ACTIVE instance as we walk forward.
+	for _, tok := range []string{"joining", "leaving", "dead"} {
+		addr, err := r.Get(hashToken(tok))
+		if err != nil {
+			t.Fatalf("unexpected error looking up %s: %v", tok, err)
+		}
+		if addr != "healthy" {
+			t.Fatalf("looking up %s: expected to land on healthy, got %s", tok, addr)
+		}
+	}
+}
+
+func TestRing_Get_EmptyRing(t *testing.T) {
+	r := newTestRing()
+	if _, err := r.Get(0); err != ErrEmptyRing {
+		t.Fatalf("expected ErrEmptyRing, got %v", err)
+	}
+}
+
+func TestRing_Get_AllDead(t *testing.T) {
+	r := newTestRing(instance("dead", ACTIVE, 5*time.Minute))
+	if _, err := r.Get(hashToken("dead")); err != ErrEmptyRing {
+		t.Fatalf("expected ErrEmptyRing, got %v", err)
+	}
+}
+
+func TestDesc_Merge(t *testing.T) {
+	a := NewDesc()
+	a.Instances["x"] = InstanceDesc{Addr: "x", State: ACTIVE, Timestamp: 100}
+	a.Instances["y"] = InstanceDesc{Addr: "y", State: ACTIVE, Timestamp: 100}
+
+	b := NewDesc()
+	b.Instances["x"] = InstanceDesc{Addr: "x", State: ACTIVE, Timestamp: 50} // stale vs a
+	b.Instances["z"] = InstanceDesc{Addr: "z", State: JOINING, Timestamp: 200}
+
+	merged := b.Merge(a).(*Desc)
+
+	if got := merged.Instances["x"].Timestamp; got != 100 {
+		t.Errorf("expected newer timestamp for x to win, got %d", got)
+	}
+	if _, ok := merged.Instances["y"]; !ok {
+		t.Errorf("expected y, only known to a, to survive the merge")
+	}
+	if _, ok := merged.Instances["z"]; !ok {
+		t.Errorf("expected z, only known to b, to survive the merge")
+	}
+}
@@ -14,8 +14,10 @@ import (
 	"strings"
 	"text/template"
 
+	"github.com/BurntSushi/toml"
 	"github.com/Masterminds/sprig"
 	"github.com/dustin/go-humanize/english"
+	yaml "gopkg.in/yaml.v2"
 )
 
 // Extension is the required file extension for processed files.
@@ -71,33 +73,39 @@ func NewMain() *Main {
 	}
 }
 
+// repeatedFlag collects every occurrence of a flag that may be passed more
+// than once, in the order they were given.
+type repeatedFlag []string
+
+func (f *repeatedFlag) String() string { return strings.Join(*f, ",") }
+func (f *repeatedFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
 // ParseFlags parses the command line flags from args.
 func (m *Main) ParseFlags(args []string) error {
 	fs := flag.NewFlagSet("tmp", flag.ContinueOnError)
 	fs.SetOutput(m.Stderr)
-	data := fs.String("data", "", "json data")
+
+	var dataSources repeatedFlag
+	fs.Var(&dataSources, "data", "data source: a literal value, or @file to read from a file; may be repeated, each one deep-merged over the previous")
+	dataFormat := fs.String("data-format", "", "format of -data values: json, yaml or toml. Auto-detected from the file extension for @file sources; defaults to json otherwise")
+
+	var setOverrides repeatedFlag
+	fs.Var(&setOverrides, "set", "key=value override applied after every -data source, parsed as a YAML scalar; may be repeated")
+
 	fs.BoolVar(&m.NoHeader, "no-header", false, "hide warning header")
 	fs.StringVar(&m.OutputPath, "o", "", "output file")
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 
-	// Parse JSON data.
-	if *data != "" {
-		// If the data has a @-prefix then read from a file.
-		buf := []byte(*data)
-		if strings.HasPrefix(*data, "@") {
-			b, err := m.FileReadWriter.ReadFile(strings.TrimPrefix(*data, "@"))
-			if err != nil {
-				return err
-			}
-			buf = b
-		}
-
-		if err := json.Unmarshal(buf, &m.Data); err != nil {
-			return err
-		}
+	data, err := m.buildData(dataSources, *dataFormat, setOverrides)
+	if err != nil {
+		return err
 	}
+	m.Data = data
 
 	// All arguments are considered paths to process.
 	m.Paths = fs.Args()
@@ -105,6 +113,174 @@ func (m *Main) ParseFlags(args []string) error {
 	return nil
 }
 
+// buildData reads each of sources in order, deep-merging them together, then
+// applies overrides on top.
+func (m *Main) buildData(sources []string, format string, overrides []string) (interface{}, error) {
+	if len(sources) == 0 {
+		if len(overrides) == 0 {
+			return nil, nil
+		}
+		return m.applyOverrides(map[string]interface{}{}, overrides)
+	}
+
+	// A single data source is allowed to be any JSON/YAML/TOML value
+	// (including a top-level array or scalar), matching the historical
+	// behaviour of the tool's single -data flag. Merging only makes sense,
+	// and is only required, once there's more than one source to combine.
+	if len(sources) == 1 && len(overrides) == 0 {
+		return m.readDataSource(sources[0], format)
+	}
+
+	merged := map[string]interface{}{}
+	for _, src := range sources {
+		v, err := m.readDataSource(src, format)
+		if err != nil {
+			return nil, err
+		}
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("-data %q: merging multiple -data sources requires each to be a map/object, got %T", src, v)
+		}
+		deepMerge(merged, obj)
+	}
+
+	return m.applyOverrides(merged, overrides)
+}
+
+func (m *Main) applyOverrides(data map[string]interface{}, overrides []string) (interface{}, error) {
+	for _, kv := range overrides {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("-set %q: expected key=value", kv)
+		}
+		key, value := parts[0], parts[1]
+
+		var parsed interface{}
+		if err := yaml.Unmarshal([]byte(value), &parsed); err != nil {
+			return nil, fmt.Errorf("-set %q: %v", kv, err)
+		}
+		data[key] = parsed
+	}
+	return data, nil
+}
+
+// readDataSource reads and decodes a single -data value: either a literal
+// value or, if prefixed with "@", the contents of a file. format overrides
+// auto-detection when non-empty.
+func (m *Main) readDataSource(src string, format string) (interface{}, error) {
+	var buf []byte
+	if strings.HasPrefix(src, "@") {
+		path := strings.TrimPrefix(src, "@")
+		b, err := m.FileReadWriter.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		buf = b
+
+		if format == "" {
+			format = formatFromExtension(path)
+		}
+	} else {
+		buf = []byte(src)
+	}
+
+	if format == "" {
+		format = "json"
+	}
+
+	return decodeData(buf, format)
+}
+
+// formatFromExtension maps a file extension to a -data-format value,
+// defaulting to json for anything it doesn't recognise.
+func formatFromExtension(path string) string {
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".toml":
+		return "toml"
+	default:
+		return "json"
+	}
+}
+
+// decodeData unmarshals buf according to format, normalising the result to
+// plain map[string]interface{}/[]interface{} values so that data from
+// different formats deep-merges the same way.
+func decodeData(buf []byte, format string) (interface{}, error) {
+	switch format {
+	case "json":
+		var v interface{}
+		if err := json.Unmarshal(buf, &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	case "yaml":
+		var v interface{}
+		if err := yaml.Unmarshal(buf, &v); err != nil {
+			return nil, err
+		}
+		return normalizeYAML(v), nil
+	case "toml":
+		var v interface{}
+		if _, err := toml.Decode(string(buf), &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	default:
+		return nil, fmt.Errorf("unrecognized data format: %s", format)
+	}
+}
+
+// normalizeYAML converts the map[interface{}]interface{} values that
+// gopkg.in/yaml.v2 produces into map[string]interface{}, recursively, so
+// that YAML-sourced data merges and templates the same way JSON- or
+// TOML-sourced data does.
+func normalizeYAML(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			out[fmt.Sprint(k)] = normalizeYAML(val)
+		}
+		return out
+	case map[string]interface{}:
+		for k, val := range v {
+			v[k] = normalizeYAML(val)
+		}
+		return v
+	case []interface{}:
+		for i, val := range v {
+			v[i] = normalizeYAML(val)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// deepMerge merges src into dst in place: for keys present in both where
+// both values are maps, it recurses; otherwise src's value wins, so later
+// sources override earlier ones.
+func deepMerge(dst, src map[string]interface{}) {
+	for k, srcVal := range src {
+		dstVal, ok := dst[k]
+		if !ok {
+			dst[k] = srcVal
+			continue
+		}
+
+		dstMap, dstIsMap := dstVal.(map[string]interface{})
+		srcMap, srcIsMap := srcVal.(map[string]interface{})
+		if dstIsMap && srcIsMap {
+			deepMerge(dstMap, srcMap)
+			continue
+		}
+
+		dst[k] = srcVal
+	}
+}
+
 // Run executes the program.
 func (m *Main) Run() error {
 	// Verify we have at least one path.
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDeepMerge(t *testing.T) {
+	dst := map[string]interface{}{
+		"name": "a",
+		"nested": map[string]interface{}{
+			"keep":     "dst",
+			"override": "dst",
+		},
+		"replaced": map[string]interface{}{"x": 1},
+	}
+	src := map[string]interface{}{
+		"name": "b",
+		"nested": map[string]interface{}{
+			"override": "src",
+			"added":    "src",
+		},
+		"replaced": "not-a-map-anymore",
+	}
+
+	deepMerge(dst, src)
+
+	want := map[string]interface{}{
+		"name": "b",
+		"nested": map[string]interface{}{
+			"keep":     "dst",
+			"override": "src",
+			"added":    "src",
+		},
+		"replaced": "not-a-map-anymore",
+	}
+	if !reflect.DeepEqual(dst, want) {
+		t.Fatalf("deepMerge() = %#v, want %#v", dst, want)
+	}
+}
+
+func TestNormalizeYAML(t *testing.T) {
+	in := map[interface{}]interface{}{
+		"name": "cortex",
+		"nested": map[interface{}]interface{}{
+			"count": 3,
+		},
+		"list": []interface{}{
+			map[interface{}]interface{}{"k": "v"},
+		},
+	}
+
+	got := normalizeYAML(in)
+
+	want := map[string]interface{}{
+		"name": "cortex",
+		"nested": map[string]interface{}{
+			"count": 3,
+		},
+		"list": []interface{}{
+			map[string]interface{}{"k": "v"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("normalizeYAML() = %#v, want %#v", got, want)
+	}
+}
+
+func TestBuildDataMergesMultipleSources(t *testing.T) {
+	m := NewMain()
+	m.FileReadWriter = &fakeFileReadWriter{files: map[string]string{
+		"base.yaml": "name: base\nnested:\n  a: 1\n",
+		"over.json": `{"nested": {"b": 2}, "extra": true}`,
+	}}
+
+	data, err := m.buildData([]string{"@base.yaml", "@over.json"}, "", []string{"name=override", "count=3"})
+	if err != nil {
+		t.Fatalf("buildData() error = %v", err)
+	}
+
+	want := map[string]interface{}{
+		"name": "override",
+		"nested": map[string]interface{}{
+			"a": 1,
+			"b": 2.0, // decoded from JSON, which always yields float64 for numbers
+		},
+		"extra": true,
+		"count": 3,
+	}
+	if !reflect.DeepEqual(data, want) {
+		t.Fatalf("buildData() = %#v, want %#v", data, want)
+	}
+}
+
+// TestProcessUsesSprigAndPluralize verifies the generation pipeline still
+// wires up the sprig funcMap and the custom pluralize func unchanged after
+// the data-source changes.
+func TestProcessUsesSprigAndPluralize(t *testing.T) {
+	m := NewMain()
+	frw := &fakeFileReadWriter{files: map[string]string{
+		"greeting.txt.tmpl": "{{ .Name | upper }} has {{ .Count }} {{ pluralize \"box\" }}\n",
+	}}
+	m.FileReadWriter = frw
+	m.OS = &fakeOS{}
+	m.NoHeader = true
+	m.Data = map[string]interface{}{"Name": "cortex", "Count": 2}
+
+	if err := m.process("greeting.txt.tmpl"); err != nil {
+		t.Fatalf("process() error = %v", err)
+	}
+
+	got := frw.files["greeting.txt"]
+	want := "CORTEX has 2 boxes\n"
+	if got != want {
+		t.Fatalf("process() wrote %q, want %q", got, want)
+	}
+}
+
+type fakeFileReadWriter struct {
+	files map[string]string
+}
+
+func (f *fakeFileReadWriter) ReadFile(filename string) ([]byte, error) {
+	content, ok := f.files[filename]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return []byte(content), nil
+}
+
+func (f *fakeFileReadWriter) WriteFile(filename string, data []byte, perm os.FileMode) error {
+	f.files[filename] = string(data)
+	return nil
+}
+
+type fakeOS struct{}
+
+func (f *fakeOS) Stat(filename string) (os.FileInfo, error) {
+	return fakeFileInfo{}, nil
+}
+
+type fakeFileInfo struct{}
+
+func (fakeFileInfo) Name() string       { return "" }
+func (fakeFileInfo) Size() int64        { return 0 }
+func (fakeFileInfo) Mode() os.FileMode  { return 0644 }
+func (fakeFileInfo) ModTime() time.Time { return time.Time{} }
+func (fakeFileInfo) IsDir() bool        { return false }
+func (fakeFileInfo) Sys() interface{}   { return nil }